@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"time"
+)
+
+const (
+	// accountDeletionTokenTTL — срок действия кода подтверждения удаления
+	accountDeletionTokenTTL = 45 * time.Minute
+	// accountDeletionGrace — сколько удаление аккаунта можно отменить
+	accountDeletionGrace = 14 * 24 * time.Hour
+)
+
+// RequestAccountDeletion отправляет владельцу код подтверждения удаления.
+// Код приходит на email, поэтому подтвердить удаление может и пользователь,
+// входящий только через SSO.
+func (s *Service) RequestAccountDeletion(userID int64) error {
+	user, err := s.models.Users.Get(int(userID))
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrInvalidCredentials
+	}
+
+	// Действителен только последний выданный код
+	err = s.models.Tokens.DeleteAllForUser(data.ScopeAccountDeletion, userID)
+	if err != nil {
+		return err
+	}
+
+	token, err := s.models.Tokens.New(userID, accountDeletionTokenTTL, data.ScopeAccountDeletion)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(user.Email, "account_deletion.tmpl", map[string]any{
+		"name":                 user.Name,
+		"accountDeletionToken": token.Plaintext,
+		"graceDays":            int(accountDeletionGrace.Hours() / 24),
+	})
+
+	return nil
+}
+
+// DeleteAccount по коду из письма планирует удаление аккаунта и завершает
+// все сессии и персональные токены. До возвращенного момента удаление
+// можно отменить, снова войдя в аккаунт.
+func (s *Service) DeleteAccount(v *validator.Validator, userID int64, tokenPlaintext string) (time.Time, error) {
+	if data.ValidateTokenPlaintext(v, tokenPlaintext); !v.Valid() {
+		return time.Time{}, ErrFailedValidation
+	}
+
+	user, err := s.models.Users.GetForToken(data.ScopeAccountDeletion, tokenPlaintext)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return time.Time{}, err
+	}
+
+	// Код, выданный другому пользователю, не раскрывается
+	if user == nil || int64(user.ID) != userID {
+		v.AddError("token", "invalid or expired account deletion token")
+		return time.Time{}, ErrFailedValidation
+	}
+
+	scheduledAt := time.Now().Add(accountDeletionGrace)
+
+	err = s.models.Users.ScheduleDeletion(user.ID, scheduledAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	err = s.models.Tokens.DeleteAllForUser(data.ScopeAccountDeletion, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	err = s.models.AuthorizationTokens.RevokeAllForUser(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	err = s.models.PersonalAccessTokens.RevokeAllForUser(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	s.sendMail(user.Email, "account_deletion_scheduled.tmpl", map[string]any{
+		"name":        user.Name,
+		"scheduledAt": scheduledAt.UTC().Format("02.01.2006 15:04 UTC"),
+	})
+
+	return scheduledAt, nil
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+)
+
+// ErrTooManyAttempts — вход временно запрещен после серии неудачных попыток.
+// Конкретная пауза передается в ThrottledError.
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// ThrottledError сообщает, через сколько можно повторить попытку входа
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// RetryAfterSeconds округляет паузу вверх для заголовка Retry-After
+func (e *ThrottledError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// checkThrottle не пускает к проверке пароля или кода, пока не истекла пауза
+// после предыдущих ошибок
+func (s *Service) checkThrottle(email string, user *model.User, client ClientInfo) error {
+	retryAfter, locked, err := s.models.LoginAttempts.Check(email, client.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	if retryAfter <= 0 {
+		return nil
+	}
+
+	err = s.logSecurityEvent(data.SecurityEventLoginThrottled, email, user, client)
+	if err != nil {
+		return err
+	}
+
+	return &ThrottledError{RetryAfter: retryAfter, Locked: locked}
+}
+
+// recordFailure учитывает неудачную попытку и, если она привела к блокировке,
+// уведомляет владельца аккаунта
+func (s *Service) recordFailure(eventType, email string, user *model.User, client ClientInfo) error {
+	locked, err := s.models.LoginAttempts.RecordFailure(email, client.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	err = s.logSecurityEvent(eventType, email, user, client)
+	if err != nil {
+		return err
+	}
+
+	if !locked {
+		return nil
+	}
+
+	err = s.logSecurityEvent(data.SecurityEventAccountLocked, email, user, client)
+	if err != nil {
+		return err
+	}
+
+	if user != nil {
+		s.sendMail(user.Email, "account_locked.tmpl", map[string]any{
+			"name":      user.Name,
+			"minutes":   int(data.AccountLockDuration.Minutes()),
+			"ipAddress": client.IPAddress,
+		})
+	}
+
+	return nil
+}
+
+// recordSuccess сбрасывает счетчик неудачных попыток аккаунта
+func (s *Service) recordSuccess(user *model.User, client ClientInfo) error {
+	err := s.models.LoginAttempts.Reset(user.Email)
+	if err != nil {
+		return err
+	}
+
+	return s.logSecurityEvent(data.SecurityEventLoginSucceeded, user.Email, user, client)
+}
+
+// UnlockAccount снимает блокировку входа по запросу администратора
+func (s *Service) UnlockAccount(userID int64, client ClientInfo) error {
+	user, err := s.models.Users.Get(int(userID))
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return data.ErrRecordNotFound
+	}
+
+	err = s.models.LoginAttempts.Unlock(user.Email)
+	if err != nil {
+		return err
+	}
+
+	return s.logSecurityEvent(data.SecurityEventAccountUnlocked, user.Email, user, client)
+}
+
+func (s *Service) logSecurityEvent(eventType, email string, user *model.User, client ClientInfo) error {
+	event := data.SecurityEvent{
+		Email:     email,
+		Type:      eventType,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	}
+
+	if user != nil {
+		userID := int64(user.ID)
+		event.UserID = &userID
+	}
+
+	return s.models.SecurityEvents.Insert(event)
+}
@@ -2,65 +2,97 @@ package auth
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type contextKey string
 
 const (
-	ContextUserID   contextKey = "user_id"
-	ContextUserRole contextKey = "user_role"
+	ContextUserID      contextKey = "user_id"
+	ContextUserRole    contextKey = "user_role"
+	ContextSessionID   contextKey = "session_id"
+	ContextClientInfo  contextKey = "client_info"
+	ContextPermissions contextKey = "permissions"
+	ContextMFA         contextKey = "mfa"
+	ContextTokenScopes contextKey = "token_scopes"
 )
 
-// UserClaims содержит информацию о пользователе, которую мы включаем в JWT токен
+// UserClaims содержит информацию о пользователе, которую мы включаем в JWT токен.
+// ID пользователя передается в стандартном claim sub.
 type UserClaims struct {
-	UserID int64  `json:"user_id"`
-	Role   string `json:"role"`
-	jwt.StandardClaims
+	UserID    int64  `json:"-"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	// MFA — вход подтвержден вторым фактором
+	MFA bool `json:"mfa,omitempty"`
+	jwt.RegisteredClaims
 }
 
-// JWTManager отвечает за генерацию и проверку JWT токенов
+// JWTManager отвечает за генерацию и проверку JWT токенов. Новые токены
+// подписываются активным ключом, проверка принимает любой известный kid,
+// поэтому ключи можно менять, не разлогинивая пользователей.
 type JWTManager struct {
-	secretKey     string
+	keys          map[string]*Key
+	active        *Key
+	issuer        string
+	audience      string
 	tokenDuration time.Duration
 }
 
 // NewJWTManager создает новый JWTManager
-func NewJWTManager(secretKey string, tokenDuration time.Duration) *JWTManager {
-	return &JWTManager{
-		secretKey:     secretKey,
+func NewJWTManager(keys []*Key, activeKeyID, issuer, audience string, tokenDuration time.Duration) (*JWTManager, error) {
+	manager := &JWTManager{
+		keys:          make(map[string]*Key, len(keys)),
+		issuer:        issuer,
+		audience:      audience,
 		tokenDuration: tokenDuration,
 	}
-}
 
-// Generate генерирует новый JWT токен для переданного UserID и Role
-func (manager *JWTManager) Generate(userID int64, role string) (string, error) {
-	claims := &UserClaims{
-		UserID: userID,
-		Role:   role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(manager.tokenDuration).Unix(),
-		},
+	for _, key := range keys {
+		manager.keys[key.ID] = key
+	}
+
+	active, ok := manager.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found", activeKeyID)
 	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("active signing key %q has no private part", activeKeyID)
+	}
+	manager.active = active
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(manager.secretKey))
+	return manager, nil
 }
 
-// GenerateRefresh генерирует новый Refresh токен
-func (manager *JWTManager) GenerateRefresh(userID int64, role string) (string, error) {
+// Generate генерирует новый JWT токен для переданного UserID и Role,
+// привязанный к сессии sessionID
+func (manager *JWTManager) Generate(userID int64, role string, sessionID string, mfa bool) (string, error) {
+	now := time.Now()
+
 	claims := &UserClaims{
-		UserID: userID,
-		Role:   role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(7 * 24 * time.Hour).Unix(), // Refresh токен действует 7 дней
+		Role:      role,
+		SessionID: sessionID,
+		MFA:       mfa,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    manager.issuer,
+			Subject:   strconv.FormatInt(userID, 10),
+			Audience:  jwt.ClaimStrings{manager.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(manager.tokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(manager.secretKey))
+	token := jwt.NewWithClaims(manager.active.method, claims)
+	token.Header["kid"] = manager.active.ID
+
+	return token.SignedString(manager.active.private)
 }
 
 // Verify проверяет JWT токен и возвращает UserClaims
@@ -69,11 +101,24 @@ func (manager *JWTManager) Verify(tokenString string) (*UserClaims, error) {
 		tokenString,
 		&UserClaims{},
 		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			kid, _ := token.Header["kid"].(string)
+
+			key, ok := manager.keys[kid]
+			if !ok {
+				return nil, errors.New("unknown signing key")
+			}
+
+			// Алгоритм определяется ключом, а не заголовком токена
+			if token.Method.Alg() != key.method.Alg() {
 				return nil, errors.New("unexpected signing method")
 			}
-			return []byte(manager.secretKey), nil
+
+			return key.public, nil
 		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(manager.issuer),
+		jwt.WithAudience(manager.audience),
+		jwt.WithExpirationRequired(),
 	)
 
 	if err != nil {
@@ -85,5 +130,24 @@ func (manager *JWTManager) Verify(tokenString string) (*UserClaims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	claims.UserID, err = strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid token subject")
+	}
+
 	return claims, nil
 }
+
+// JWKS возвращает публичные ключи всех известных kid для /.well-known/jwks.json
+func (manager *JWTManager) JWKS() []JWK {
+	jwks := make([]JWK, 0, len(manager.keys))
+	for _, key := range manager.keys {
+		jwks = append(jwks, key.JWK())
+	}
+
+	sort.Slice(jwks, func(i, j int) bool {
+		return jwks[i].KeyID < jwks[j].KeyID
+	})
+
+	return jwks
+}
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+)
+
+const (
+	// mfaChallengeTTL — сколько действует токен между вводом пароля и кода
+	mfaChallengeTTL = 5 * time.Minute
+	// recoveryCodeCount — сколько кодов восстановления выдается за раз
+	recoveryCodeCount = 10
+)
+
+// MFAChallenge выдается вместо токенов, если у пользователя включена 2FA
+type MFAChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// TOTPEnrollment — данные для добавления аккаунта в приложение-аутентификатор
+type TOTPEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// startSession выдает токены или, если включена 2FA, запрашивает второй фактор
+func (s *Service) startSession(user *model.User, client ClientInfo) (*Session, error) {
+	totp, err := s.models.MFA.GetTOTP(int64(user.ID))
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if totp == nil || !totp.Enabled {
+		return s.newSession(user, client, false)
+	}
+
+	token, err := s.models.Tokens.New(int64(user.ID), mfaChallengeTTL, data.ScopeMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		MFAChallenge: &MFAChallenge{
+			Token:     token.Plaintext,
+			ExpiresAt: token.Expiry,
+		},
+	}, nil
+}
+
+// VerifyMFA завершает вход: принимает токен MFAChallenge и код из приложения
+// или код восстановления
+func (s *Service) VerifyMFA(v *validator.Validator, challengeToken, code string, client ClientInfo) (*Session, error) {
+	data.ValidateTokenPlaintext(v, challengeToken)
+	v.Check(code != "", "code", "must be provided")
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	user, err := s.models.Users.GetForToken(data.ScopeMFAChallenge, challengeToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, ErrInvalidCredentials
+		default:
+			return nil, err
+		}
+	}
+
+	// Код из шести цифр перебирается быстрее пароля, поэтому ошибки второго
+	// фактора учитываются в том же счетчике попыток
+	err = s.checkThrottle(user.Email, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.checkSecondFactor(int64(user.ID), code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		err = s.recordFailure(data.SecurityEventMFAFailed, user.Email, user, client)
+		if err != nil {
+			return nil, err
+		}
+
+		v.AddError("code", "is invalid")
+		return nil, ErrFailedValidation
+	}
+
+	err = s.models.Tokens.DeleteAllForUser(data.ScopeMFAChallenge, int64(user.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordSuccess(user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newSession(user, client, true)
+}
+
+// checkSecondFactor принимает код TOTP или неиспользованный код восстановления
+func (s *Service) checkSecondFactor(userID int64, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	totp, err := s.models.MFA.GetTOTP(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	if !totp.Enabled {
+		return false, nil
+	}
+
+	if len(code) == totpDigits {
+		secret, err := s.secrets.open(totp.SecretEncrypted)
+		if err != nil {
+			return false, err
+		}
+
+		step, ok := validateTOTP(secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		return s.models.MFA.UseTOTPStep(userID, step)
+	}
+
+	return s.models.MFA.UseRecoveryCode(userID, normalizeRecoveryCode(code))
+}
+
+// EnrollTOTP создает новый секрет. 2FA включится только после ConfirmTOTP.
+func (s *Service) EnrollTOTP(userID int64) (*TOTPEnrollment, error) {
+	user, err := s.models.Users.Get(int(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.secrets.seal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.models.MFA.SetPendingTOTP(userID, encrypted)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, ErrMFAAlreadyEnabled
+		default:
+			return nil, err
+		}
+	}
+
+	return &TOTPEnrollment{
+		Secret:          totpEncoding.EncodeToString(secret),
+		ProvisioningURI: totpProvisioningURI(secret, user.Email),
+	}, nil
+}
+
+// ConfirmTOTP включает 2FA по первому коду из приложения и возвращает коды
+// восстановления. Они показываются один раз.
+func (s *Service) ConfirmTOTP(v *validator.Validator, userID int64, code string) ([]string, error) {
+	v.Check(code != "", "code", "must be provided")
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	totp, err := s.models.MFA.GetTOTP(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, ErrMFANotEnabled
+		default:
+			return nil, err
+		}
+	}
+
+	if totp.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.secrets.open(totp.SecretEncrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := validateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		v.AddError("code", "is invalid")
+		return nil, ErrFailedValidation
+	}
+
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.models.MFA.ConfirmTOTP(userID, step, hashableRecoveryCodes(codes))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, ErrMFAAlreadyEnabled
+		default:
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP выключает 2FA после проверки текущего кода
+func (s *Service) DisableTOTP(v *validator.Validator, userID int64, code string) error {
+	v.Check(code != "", "code", "must be provided")
+	if !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	ok, err := s.checkSecondFactor(userID, code)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		v.AddError("code", "is invalid")
+		return ErrFailedValidation
+	}
+
+	return s.models.MFA.DeleteTOTP(userID)
+}
+
+// RegenerateRecoveryCodes выдает новый набор кодов восстановления взамен старого
+func (s *Service) RegenerateRecoveryCodes(v *validator.Validator, userID int64, code string) ([]string, error) {
+	v.Check(code != "", "code", "must be provided")
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	ok, err := s.checkSecondFactor(userID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		v.AddError("code", "is invalid")
+		return nil, ErrFailedValidation
+	}
+
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.models.MFA.ReplaceRecoveryCodes(userID, hashableRecoveryCodes(codes))
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCodes возвращает коды вида XXXXX-XXXXX
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 7)
+
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+
+		code := totpEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes, nil
+}
+
+func hashableRecoveryCodes(codes []string) []string {
+	normalized := make([]string, len(codes))
+	for i, code := range codes {
+		normalized[i] = normalizeRecoveryCode(code)
+	}
+
+	return normalized
+}
+
+// normalizeRecoveryCode позволяет вводить код без дефиса и в любом регистре
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrOIDCDisabled     = errors.New("oidc login is not configured")
+	ErrEmailNotVerified = errors.New("identity provider did not verify the email address")
+)
+
+// oidcRequestTTL — сколько пользователь может провести на странице входа IdP
+const oidcRequestTTL = 10 * time.Minute
+
+// OIDCConfig описывает клиента университетского провайдера OpenID Connect
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Имена нестандартных claims, в которых IdP передает факультет
+	// и принадлежность (студент, преподаватель и т.д.)
+	FacultyClaim     string
+	AffiliationClaim string
+}
+
+// OIDCProvider выполняет authorization code flow с PKCE
+type OIDCProvider struct {
+	oauth2           oauth2.Config
+	verifier         *oidc.IDTokenVerifier
+	facultyClaim     string
+	affiliationClaim string
+}
+
+// NewOIDCProvider загружает discovery-документ провайдера
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCProvider{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		verifier:         provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		facultyClaim:     cfg.FacultyClaim,
+		affiliationClaim: cfg.AffiliationClaim,
+	}, nil
+}
+
+// Identity — сведения о пользователе из ID токена провайдера
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Lastname      string
+	Faculty       *string
+	Affiliations  []string
+}
+
+// Role выводит роль нового пользователя из принадлежности. Администраторов
+// IdP не назначает.
+func (i Identity) Role() model.Role {
+	for _, affiliation := range i.Affiliations {
+		switch strings.ToLower(affiliation) {
+		case "faculty", "teacher", "staff", "employee":
+			return model.RoleTeacher
+		}
+	}
+
+	return model.RoleStudent
+}
+
+func (p *OIDCProvider) exchange(ctx context.Context, code string, request *data.OIDCRequest) (*Identity, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(request.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidCredentials)
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if idToken.Nonce != request.Nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidCredentials)
+	}
+
+	var claims map[string]any
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: claims["email_verified"] == true,
+		Name:          stringClaim(claims, "given_name"),
+		Lastname:      stringClaim(claims, "family_name"),
+		Affiliations:  stringsClaim(claims, p.affiliationClaim),
+	}
+
+	// Не все провайдеры разделяют имя и фамилию
+	if identity.Name == "" || identity.Lastname == "" {
+		first, last, _ := strings.Cut(stringClaim(claims, "name"), " ")
+		if identity.Name == "" {
+			identity.Name = first
+		}
+		if identity.Lastname == "" {
+			identity.Lastname = last
+		}
+	}
+
+	if faculty := stringClaim(claims, p.facultyClaim); faculty != "" {
+		identity.Faculty = &faculty
+	}
+
+	if identity.Email == "" {
+		return nil, fmt.Errorf("%w: id token has no email claim", ErrInvalidCredentials)
+	}
+
+	return identity, nil
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	s, _ := claims[name].(string)
+	return strings.TrimSpace(s)
+}
+
+// stringsClaim читает claim, который может быть как строкой, так и массивом строк
+func stringsClaim(claims map[string]any, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// EnableOIDC включает вход через провайдера OpenID Connect
+func (s *Service) EnableOIDC(provider *OIDCProvider) {
+	s.oidc = provider
+}
+
+// BeginOIDC начинает вход через провайдера и возвращает адрес, на который
+// нужно перенаправить пользователя
+func (s *Service) BeginOIDC() (string, error) {
+	if s.oidc == nil {
+		return "", ErrOIDCDisabled
+	}
+
+	state, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.models.UserIdentities.SaveRequest(state, data.OIDCRequest{Nonce: nonce, CodeVerifier: verifier}, oidcRequestTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return s.oidc.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// CompleteOIDC обменивает код авторизации на ID токен, находит или создает
+// пользователя и выдает те же токены, что и вход по паролю
+func (s *Service) CompleteOIDC(ctx context.Context, state, code string, client ClientInfo) (*Session, error) {
+	if s.oidc == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	if state == "" || code == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	request, err := s.models.UserIdentities.TakeRequest(state)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, ErrInvalidCredentials
+		default:
+			return nil, err
+		}
+	}
+
+	identity, err := s.oidc.exchange(ctx, code, request)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userForIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, client)
+}
+
+// userForIdentity ищет пользователя по привязанной учетной записи IdP, затем
+// по email; если такого нет, регистрирует нового
+func (s *Service) userForIdentity(identity *Identity) (*model.User, error) {
+	userID, err := s.models.UserIdentities.GetUserID(identity.Issuer, identity.Subject)
+	switch {
+	case err == nil:
+		user, err := s.models.Users.Get(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrInvalidCredentials
+		}
+		return user, nil
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return nil, err
+	}
+
+	user, err := s.models.Users.GetByEmail(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		// Привязываем существующий аккаунт, только если IdP подтвердил,
+		// что email принадлежит этому человеку
+		if !identity.EmailVerified {
+			return nil, ErrEmailNotVerified
+		}
+
+		if !user.Activated {
+			err = s.models.Users.Activate(user.ID)
+			if err != nil {
+				return nil, err
+			}
+			user.Activated = true
+		}
+	} else {
+		user, err = s.registerIdentity(identity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.models.UserIdentities.Link(user.ID, identity.Issuer, identity.Subject, identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *Service) registerIdentity(identity *Identity) (*model.User, error) {
+	// Пароль неизвестен даже владельцу: войти по паролю можно будет только
+	// после сброса
+	password, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Email:        identity.Email,
+		Name:         identity.Name,
+		Lastname:     identity.Lastname,
+		PasswordHash: string(hashedPassword),
+		Role:         identity.Role(),
+		Faculty:      identity.Faculty,
+	}
+
+	err = s.models.Users.Insert(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.EmailVerified {
+		err = s.models.Users.Activate(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		user.Activated = true
+	} else {
+		err = s.sendActivation(user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
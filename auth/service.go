@@ -0,0 +1,436 @@
+package auth
+
+import (
+	"errors"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+var (
+	ErrFailedValidation   = errors.New("failed validation")
+	ErrInvalidCredentials = errors.New("invalid authentication credentials")
+	ErrAlreadyActivated   = errors.New("account already activated")
+)
+
+const (
+	// activationTokenTTL — срок действия ссылки подтверждения email
+	activationTokenTTL = 3 * 24 * time.Hour
+	// passwordResetTokenTTL — срок действия кода сброса пароля
+	passwordResetTokenTTL = 45 * time.Minute
+)
+
+// MailFunc отправляет письмо по шаблону в фоне
+type MailFunc func(recipient, templateFile string, data any)
+
+// ClientInfo описывает устройство, с которого выполняется вход
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// Session — результат успешной регистрации или входа. Если у пользователя
+// включена 2FA, вход по паролю возвращает только MFAChallenge, а токены
+// выдаются после ввода кода (см. VerifyMFA).
+type Session struct {
+	User         *model.User
+	AccessToken  string
+	RefreshToken string
+	MFAChallenge *MFAChallenge
+	// MFAEnrollmentRequired — для роли пользователя 2FA обязательна, но еще
+	// не настроена; до настройки привилегированные действия недоступны
+	MFAEnrollmentRequired bool
+}
+
+// Service содержит общую логику аутентификации для REST-обработчиков
+// и GraphQL-резолверов
+type Service struct {
+	models   data.Models
+	jwt      *JWTManager
+	sendMail MailFunc
+	oidc     *OIDCProvider
+	secrets  *secretBox
+}
+
+// NewService создает новый Service. mfaKey — 32-байтовый ключ, которым
+// шифруются секреты TOTP.
+func NewService(models data.Models, jwt *JWTManager, sendMail MailFunc, mfaKey []byte) (*Service, error) {
+	secrets, err := newSecretBox(mfaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		models:   models,
+		jwt:      jwt,
+		sendMail: sendMail,
+		secrets:  secrets,
+	}, nil
+}
+
+// Register создает нового студента и выдает ему токены. Ошибки валидации
+// складываются в v, при этом возвращается ErrFailedValidation.
+func (s *Service) Register(v *validator.Validator, input model.RegisterInput, client ClientInfo) (*Session, error) {
+	if data.ValidateRegisterInput(v, input); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Email:                 input.Email,
+		Name:                  input.Name,
+		Lastname:              input.Lastname,
+		PasswordHash:          string(hashedPassword),
+		Role:                  model.RoleStudent,
+		ImageURL:              input.ImageURL,
+		AdditionalInformation: input.AdditionalInformation,
+		Course:                input.Course,
+		Major:                 input.Major,
+		Degree:                input.Degree,
+		Faculty:               input.Faculty,
+		CreatedAt:             time.Now().Format(time.RFC3339),
+	}
+
+	err = s.models.Users.Insert(user)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateEmail) {
+			v.AddError("email", "a user with this email address already exists")
+			return nil, ErrFailedValidation
+		}
+		return nil, err
+	}
+
+	err = s.sendActivation(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newSession(user, client, false)
+}
+
+// Activate подтверждает email владельца токена из приветственного письма
+func (s *Service) Activate(v *validator.Validator, tokenPlaintext string) (*model.User, error) {
+	if data.ValidateTokenPlaintext(v, tokenPlaintext); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	user, err := s.models.Users.GetForToken(data.ScopeActivation, tokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			return nil, ErrFailedValidation
+		default:
+			return nil, err
+		}
+	}
+
+	err = s.models.Users.Activate(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.models.Tokens.DeleteAllForUser(data.ScopeActivation, int64(user.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	user.Activated = true
+
+	return user, nil
+}
+
+// ResendActivation отправляет новое письмо подтверждения, делая прежние
+// токены недействительными
+func (s *Service) ResendActivation(userID int64) error {
+	user, err := s.models.Users.Get(int(userID))
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrInvalidCredentials
+	}
+
+	if user.Activated {
+		return ErrAlreadyActivated
+	}
+
+	err = s.models.Tokens.DeleteAllForUser(data.ScopeActivation, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.sendActivation(user)
+}
+
+func (s *Service) sendActivation(user *model.User) error {
+	token, err := s.models.Tokens.New(int64(user.ID), activationTokenTTL, data.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(user.Email, "user_welcome.tmpl", map[string]any{
+		"name":            user.Name,
+		"activationToken": token.Plaintext,
+	})
+
+	return nil
+}
+
+// RequestPasswordReset отправляет код сброса пароля. Если пользователя с
+// таким email нет, письмо не отправляется, но вызывающий об этом не узнает.
+func (s *Service) RequestPasswordReset(v *validator.Validator, email string) error {
+	if data.ValidateEmail(v, email); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	user, err := s.models.Users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	// Действителен только последний выданный код
+	err = s.models.Tokens.DeleteAllForUser(data.ScopePasswordReset, int64(user.ID))
+	if err != nil {
+		return err
+	}
+
+	token, err := s.models.Tokens.New(int64(user.ID), passwordResetTokenTTL, data.ScopePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(user.Email, "password_reset.tmpl", map[string]any{
+		"name":               user.Name,
+		"passwordResetToken": token.Plaintext,
+	})
+
+	return nil
+}
+
+// ResetPassword задает новый пароль по коду из письма, завершает все сессии
+// и отзывает персональные токены
+func (s *Service) ResetPassword(v *validator.Validator, tokenPlaintext, newPassword string) error {
+	data.ValidateTokenPlaintext(v, tokenPlaintext)
+	data.ValidatePasswordPlaintext(v, newPassword)
+	if !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	user, err := s.models.Users.GetForToken(data.ScopePasswordReset, tokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			return ErrFailedValidation
+		default:
+			return err
+		}
+	}
+
+	err = s.setPassword(user.ID, newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.models.AuthorizationTokens.RevokeAllForUser(int64(user.ID))
+	if err != nil {
+		return err
+	}
+
+	// Утекший токен бота не должен пережить восстановление доступа
+	return s.models.PersonalAccessTokens.RevokeAllForUser(int64(user.ID))
+}
+
+// ChangePassword меняет пароль после проверки текущего, завершает все сессии,
+// отзывает персональные токены и выдает новые токены устройству, с которого
+// пришел запрос. Новая сессия
+// сохраняет пройденную в текущей проверку 2FA.
+func (s *Service) ChangePassword(v *validator.Validator, userID int64, oldPassword, newPassword string, client ClientInfo, mfa bool) (*Session, error) {
+	v.Check(oldPassword != "", "oldPassword", "must be provided")
+	data.ValidatePasswordPlaintext(v, newPassword)
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	passwordHash, err := s.models.Users.GetPasswordHash(int(userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, ErrInvalidCredentials
+		default:
+			return nil, err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(oldPassword))
+	if err != nil {
+		v.AddError("oldPassword", "is incorrect")
+		return nil, ErrFailedValidation
+	}
+
+	err = s.setPassword(int(userID), newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.models.AuthorizationTokens.RevokeAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.models.PersonalAccessTokens.RevokeAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.models.Users.Get(int(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.newSession(user, client, mfa)
+}
+
+func (s *Service) setPassword(userID int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	err = s.models.Users.UpdatePassword(userID, string(hashedPassword))
+	if err != nil {
+		return err
+	}
+
+	// Коды сброса, выданные до смены пароля, больше не нужны
+	return s.models.Tokens.DeleteAllForUser(data.ScopePasswordReset, int64(userID))
+}
+
+// Login проверяет email и пароль и выдает токены
+func (s *Service) Login(v *validator.Validator, email, password string, client ClientInfo) (*Session, error) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(password != "", "password", "must be provided")
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	user, err := s.models.Users.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkThrottle(email, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		// Попытки входа в несуществующий аккаунт считаются так же, чтобы по
+		// поведению нельзя было понять, зарегистрирован ли email
+		err = s.recordFailure(data.SecurityEventLoginFailed, email, nil, client)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		err = s.recordFailure(data.SecurityEventLoginFailed, email, user, client)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	session, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// При включенной 2FA вход завершится только в VerifyMFA
+	if session.MFAChallenge == nil {
+		err = s.recordSuccess(user, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// Refresh обменивает refresh токен на новую пару токенов. Каждый refresh
+// токен одноразовый: повторное использование отзывает всю сессию.
+func (s *Service) Refresh(refreshToken string, client ClientInfo) (*Session, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	token, err := s.models.AuthorizationTokens.Rotate(refreshToken, client.UserAgent, client.IPAddress)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound), errors.Is(err, data.ErrRefreshTokenReused):
+			return nil, ErrInvalidCredentials
+		default:
+			return nil, err
+		}
+	}
+
+	user, err := s.models.Users.Get(int(token.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issue(user, token)
+}
+
+func (s *Service) newSession(user *model.User, client ClientInfo, mfa bool) (*Session, error) {
+	token, err := s.models.AuthorizationTokens.New(int64(user.ID), client.UserAgent, client.IPAddress, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, token)
+}
+
+func (s *Service) issue(user *model.User, token *data.AuthorizationToken) (*Session, error) {
+	accessToken, err := s.jwt.Generate(int64(user.ID), user.Role.String(), token.SessionID, token.MFA)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: token.Plaintext,
+	}
+
+	if !token.MFA {
+		session.MFAEnrollmentRequired, err = s.models.MFA.IsRequiredForRole(user.Role)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
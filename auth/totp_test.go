@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// Ключ и коды из приложения B RFC 6238 (SHA-1); у нас 6 цифр, поэтому
+// сравниваются младшие разряды восьмизначных кодов из RFC.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got := totpCode(rfc6238Secret, tt.unix/totpPeriod)
+		if got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name string
+		step int64
+		ok   bool
+	}{
+		{"current step", current, true},
+		{"previous step", current - 1, true},
+		{"next step", current + 1, true},
+		{"two steps ago", current - 2, false},
+		{"two steps ahead", current + 2, false},
+	}
+
+	for _, tt := range tests {
+		step, ok := validateTOTP(rfc6238Secret, totpCode(rfc6238Secret, tt.step), now)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && step != tt.step {
+			t.Errorf("%s: step = %d, want %d", tt.name, step, tt.step)
+		}
+	}
+
+	if _, ok := validateTOTP(rfc6238Secret, "12345", now); ok {
+		t.Error("code of wrong length was accepted")
+	}
+}
+
+func TestSecretBox(t *testing.T) {
+	box, err := newSecretBox(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.seal(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := box.open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, rfc6238Secret) {
+		t.Errorf("open(seal(x)) = %q, want %q", opened, rfc6238Secret)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := box.open(sealed); err == nil {
+		t.Error("tampered ciphertext was opened")
+	}
+
+	if _, err := newSecretBox(make([]byte, 16)); err == nil {
+		t.Error("16-byte key was accepted")
+	}
+}
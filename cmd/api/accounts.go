@@ -0,0 +1,253 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/image"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// purgeBatchSize — сколько аккаунтов удаляется за один проход
+const purgeBatchSize = 100
+
+// runAccountJobs периодически собирает заказанные архивы с данными, удаляет
+// просроченные архивы и аккаунты, срок отмены удаления которых истек.
+// Задачи останавливаются вместе с сервером через ctx.
+func (app *application) runAccountJobs(ctx context.Context) {
+	app.every(ctx, app.config.accounts.jobInterval, func() {
+		app.processDataExports(ctx)
+		app.deleteExpiredExports()
+		app.purgeDeletedAccounts(ctx)
+	})
+}
+
+// processDataExports собирает архивы из очереди, пока она не опустеет или
+// не будет отменен ctx
+func (app *application) processDataExports(ctx context.Context) {
+	for ctx.Err() == nil {
+		export, err := app.models.DataExports.Claim()
+		if err != nil {
+			app.logger.PrintError(fmt.Errorf("error while claiming data export: %v", err), nil)
+			return
+		}
+
+		if export == nil {
+			return
+		}
+
+		err = app.buildDataExport(export)
+		if err != nil {
+			app.logger.PrintError(fmt.Errorf("error while building data export %d: %v", export.ID, err), nil)
+
+			err = app.models.DataExports.Fail(export.ID)
+			if err != nil {
+				app.logger.PrintError(fmt.Errorf("error while failing data export: %v", err), nil)
+			}
+		}
+	}
+}
+
+// buildDataExport сохраняет архив в хранилище и отправляет владельцу ссылку
+func (app *application) buildDataExport(export *data.DataExport) error {
+	user, err := app.models.Users.Get(int(export.UserID))
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return data.ErrRecordNotFound
+	}
+
+	sections, err := app.models.DataExports.Sections(export.UserID)
+	if err != nil {
+		return err
+	}
+
+	archive, err := exportArchive(sections)
+	if err != nil {
+		return err
+	}
+
+	// Ключ случайный: по нему файл нельзя получить через /v1/images
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("exports/%d/%s.zip", export.UserID, hex.EncodeToString(randomBytes))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err = app.storages.Images.Put(ctx, key, "application/zip", bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+
+	token, expiresAt, err := app.models.DataExports.Complete(export.ID, key)
+	if err != nil {
+		return err
+	}
+
+	app.sendMail(user.Email, "data_export.tmpl", map[string]any{
+		"name":        user.Name,
+		"downloadURL": app.storages.BaseURL + "/v1/exports/" + token,
+		"expiresAt":   expiresAt.UTC().Format("02.01.2006 15:04 UTC"),
+	})
+
+	return nil
+}
+
+// exportArchive упаковывает разделы в ZIP, по JSON-файлу на раздел
+func exportArchive(sections []data.ExportSection) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for _, section := range sections {
+		w, err := zw.Create(section.Name + ".json")
+		if err != nil {
+			return nil, err
+		}
+
+		indented := new(bytes.Buffer)
+		err = json.Indent(indented, section.Data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = indented.WriteTo(w)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// deleteExpiredExports удаляет архивы, ссылки на которые истекли
+func (app *application) deleteExpiredExports() {
+	keys, err := app.models.DataExports.DeleteExpired()
+	if err != nil {
+		app.logger.PrintError(fmt.Errorf("error while deleting expired data exports: %v", err), nil)
+		return
+	}
+
+	for _, key := range keys {
+		app.deleteFile(key)
+	}
+}
+
+// purgeDeletedAccounts удаляет аккаунты, срок отмены удаления которых истек.
+// Начатое удаление аккаунта завершается даже после отмены ctx.
+func (app *application) purgeDeletedAccounts(ctx context.Context) {
+	ids, err := app.models.Users.GetDueForDeletion(purgeBatchSize)
+	if err != nil {
+		app.logger.PrintError(fmt.Errorf("error while getting accounts due for deletion: %v", err), nil)
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Сессии, открытые после подтверждения удаления, тоже завершаются
+		err := app.models.AuthorizationTokens.RevokeAllForUser(int64(id))
+		if err != nil {
+			app.logger.PrintError(fmt.Errorf("error while revoking sessions: %v", err), nil)
+			continue
+		}
+
+		files, err := app.models.Users.Purge(id)
+		if err != nil {
+			// Удаление успели отменить
+			if !errors.Is(err, data.ErrRecordNotFound) {
+				app.logger.PrintError(fmt.Errorf("error while purging account %d: %v", id, err), nil)
+			}
+			continue
+		}
+
+		err = app.models.Permissions.Invalidate(int64(id))
+		if err != nil {
+			app.logger.PrintError(fmt.Errorf("error while invalidating permissions: %v", err), nil)
+		}
+
+		for _, url := range files.ImageURLs {
+			if key, ok := app.storages.ImageKey(url); ok {
+				app.deleteImage(key)
+			}
+		}
+
+		for _, key := range files.ImageKeys {
+			app.deleteImage(key)
+		}
+
+		for _, key := range files.ObjectKeys {
+			app.deleteFile(key)
+		}
+
+		app.logger.PrintInfo("account deleted", map[string]string{"user_id": strconv.Itoa(id)})
+	}
+}
+
+// deleteImage удаляет изображение вместе с уменьшенными копиями
+func (app *application) deleteImage(key string) {
+	app.deleteFile(key)
+
+	for _, name := range []string{image.VariantSmall, image.VariantMedium, image.VariantLarge} {
+		if variantKey, ok := image.VariantKey(key, name); ok && variantKey != key {
+			app.deleteFile(variantKey)
+		}
+	}
+}
+
+// deleteFile удаляет файл из хранилища; отсутствующий файл не считается ошибкой
+func (app *application) deleteFile(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := app.storages.Images.Delete(ctx, key)
+	if err != nil && !errors.Is(err, image.ErrObjectNotFound) {
+		app.logger.PrintError(fmt.Errorf("error while deleting file %s: %v", key, err), nil)
+	}
+}
+
+// dataExportHandler перенаправляет ссылку из письма на подписанную ссылку
+// архива с коротким сроком действия
+func (app *application) dataExportHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	key, err := app.models.DataExports.GetObjectKeyForToken(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	url, err := app.storages.Images.SignedURL(r.Context(), key, app.config.storage.urlTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, url, http.StatusFound)
+}
@@ -2,25 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/olzzhas/narxozer/auth"
+	"github.com/olzzhas/narxozer/graph/middleware"
 	"github.com/olzzhas/narxozer/graph/model"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/olzzhas/narxozer/internal/validator"
 	"net/http"
-	"time"
 )
 
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Email                 string  `json:"email"`
-		Name                  string  `json:"name"`
-		Lastname              string  `json:"lastname"`
-		Password              string  `json:"password"`
-		ImageURL              *string `json:"imageURL,omitempty"`
-		AdditionalInformation *string `json:"additionalInformation,omitempty"`
-		Course                *int    `json:"course,omitempty"`
-		Major                 *string `json:"major,omitempty"`
-		Degree                *string `json:"degree,omitempty"`
-		Faculty               *string `json:"faculty,omitempty"`
-	}
+	var input model.RegisterInput
 
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
@@ -28,81 +19,99 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Проверка обязательных полей
-	if input.Email == "" || input.Name == "" || input.Lastname == "" || input.Password == "" {
-		app.failedValidationResponse(w, r, map[string]string{
-			"email":    "This field is required",
-			"name":     "This field is required",
-			"lastname": "This field is required",
-			"password": "This field is required",
-		})
+	v := validator.New()
+
+	session, err := app.auth.Register(v, input, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrFailedValidation):
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"data": sessionResponse(session)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
-		return
 	}
+}
 
-	user := &model.User{
-		Email:                 input.Email,
-		Name:                  input.Name,
-		Lastname:              input.Lastname,
-		PasswordHash:          string(hashedPassword),
-		Role:                  model.RoleStudent,
-		ImageURL:              input.ImageURL,
-		AdditionalInformation: input.AdditionalInformation,
-		Course:                input.Course,
-		Major:                 input.Major,
-		Degree:                input.Degree,
-		Faculty:               input.Faculty,
-		CreatedAt:             time.Now().Format(time.RFC3339),
+func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
 	}
 
-	err = app.models.Users.Insert(user)
+	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	accessToken, err := app.jwtManager.Generate(int64(user.ID), user.Role.String())
-	refreshToken, err := app.jwtManager.GenerateRefresh(int64(user.ID), user.Role.String())
+	v := validator.New()
+
+	session, err := app.auth.Login(v, input.Email, input.Password, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrFailedValidation):
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			app.tooManyAttemptsResponse(w, r, err)
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": sessionResponse(session)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// verifyMFAHandler — второй шаг входа при включенной 2FA
+func (app *application) verifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"user": map[string]interface{}{
-			"id":                    user.ID,
-			"email":                 user.Email,
-			"name":                  user.Name,
-			"lastname":              user.Lastname,
-			"role":                  user.Role,
-			"imageURL":              user.ImageURL,
-			"additionalInformation": user.AdditionalInformation,
-			"course":                user.Course,
-			"createdAt":             user.CreatedAt,
-			"updatedAt":             user.UpdatedAt,
-			"major":                 user.Major,
-			"degree":                user.Degree,
-			"faculty":               user.Faculty,
-		},
-		"accessToken":  accessToken,
-		"refreshToken": refreshToken,
+	v := validator.New()
+
+	session, err := app.auth.VerifyMFA(v, input.MFAToken, input.Code, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrFailedValidation):
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			app.tooManyAttemptsResponse(w, r, err)
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"data": response}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": sessionResponse(session)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&input)
@@ -111,39 +120,89 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if input.Email == "" || input.Password == "" {
-		app.failedValidationResponse(w, r, map[string]string{
-			"email":    "This field is required",
-			"password": "This field is required",
-		})
+	session, err := app.auth.Refresh(input.RefreshToken, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	user, err := app.models.Users.GetByEmail(input.Email)
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": sessionResponse(session)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// oidcLoginHandler перенаправляет пользователя на страницу входа
+// университетского SSO
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	authURL, err := app.auth.BeginOIDC()
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrOIDCDisabled):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	if user == nil {
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallbackHandler принимает код авторизации от SSO и выдает те же
+// токены, что и loginUserHandler
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if idpError := query.Get("error"); idpError != "" {
+		app.logger.PrintInfo("oidc login rejected by identity provider", map[string]string{
+			"error":             idpError,
+			"error_description": query.Get("error_description"),
+		})
 		app.invalidCredentialsResponse(w, r)
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
+	session, err := app.auth.CompleteOIDC(r.Context(), query.Get("state"), query.Get("code"), middleware.GetClientInfoFromContext(r.Context()))
 	if err != nil {
-		app.invalidCredentialsResponse(w, r)
+		switch {
+		case errors.Is(err, auth.ErrOIDCDisabled):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			app.errorResponse(w, r, http.StatusConflict, "an account with this email already exists and the identity provider has not verified the email address")
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			app.logger.PrintInfo(err.Error(), nil)
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	accessToken, err := app.jwtManager.Generate(int64(user.ID), user.Role.String())
-	refreshToken, err := app.jwtManager.GenerateRefresh(int64(user.ID), user.Role.String())
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": sessionResponse(session)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
-		return
+	}
+}
+
+func sessionResponse(session *auth.Session) map[string]interface{} {
+	if session.MFAChallenge != nil {
+		return map[string]interface{}{
+			"mfaChallenge": map[string]interface{}{
+				"token":     session.MFAChallenge.Token,
+				"expiresAt": session.MFAChallenge.ExpiresAt,
+			},
+		}
 	}
 
-	response := map[string]interface{}{
+	user := session.User
+
+	return map[string]interface{}{
 		"user": map[string]interface{}{
 			"id":                    user.ID,
 			"email":                 user.Email,
@@ -159,41 +218,19 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 			"degree":                user.Degree,
 			"faculty":               user.Faculty,
 		},
-		"accessToken":  accessToken,
-		"refreshToken": refreshToken,
-	}
-
-	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		"accessToken":           session.AccessToken,
+		"refreshToken":          session.RefreshToken,
+		"mfaEnrollmentRequired": session.MFAEnrollmentRequired,
 	}
 }
-func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		RefreshToken string `json:"refresh_token"`
-	}
-
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
 
-	claims, err := app.jwtManager.Verify(input.RefreshToken)
-	if err != nil {
-		app.invalidCredentialsResponse(w, r)
-		return
-	}
-
-	accessToken, err := app.jwtManager.Generate(claims.UserID, claims.Role)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
+// jwksHandler публикует публичные ключи, чтобы другие сервисы университета
+// могли проверять наши access токены
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
 
-	err = app.writeJSON(w, http.StatusOK, envelope{
-		"access_token": accessToken,
-	}, nil)
+	err := app.writeJSON(w, http.StatusOK, envelope{"keys": app.jwtManager.JWKS()}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -3,7 +3,10 @@ package main
 import (
 	"errors"
 	"fmt"
+	"github.com/olzzhas/narxozer/auth"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 	"net/http"
+	"strconv"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -72,6 +75,17 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
 
+// tooManyAttemptsResponse отвечает на вход во время паузы после неудачных попыток
+func (app *application) tooManyAttemptsResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var throttled *auth.ThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+	}
+
+	message := "too many failed login attempts, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
@@ -104,3 +118,25 @@ func (app *application) invalidTimeResponse(w http.ResponseWriter, r *http.Reque
 	message := "given time is not valid"
 	app.errorResponse(w, r, http.StatusBadRequest, message)
 }
+
+// unauthenticatedGraphQLResponse отвечает в формате GraphQL, чтобы клиент
+// получил ошибку с кодом UNAUTHENTICATED, а не REST-конверт
+func (app *application) unauthenticatedGraphQLResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	env := envelope{
+		"errors": gqlerror.List{{
+			Message: "invalid or missing authentication token",
+			Extensions: map[string]interface{}{
+				"code": "UNAUTHENTICATED",
+			},
+		}},
+		"data": nil,
+	}
+
+	err := app.writeJSON(w, http.StatusUnauthorized, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
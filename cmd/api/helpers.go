@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -158,6 +159,51 @@ func (app *application) background(fn func()) {
 	}()
 }
 
+// every запускает fn сразу и затем каждые interval, пока не отменен ctx.
+// Паника в fn не останавливает следующие запуски, а при остановке сервер
+// дожидается завершения текущего запуска.
+func (app *application) every(ctx context.Context, interval time.Duration, fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			app.recovered(fn)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// recovered вызывает fn, записывая панику в лог вместо падения процесса
+func (app *application) recovered(fn func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.PrintError(fmt.Errorf("%s", err), nil)
+		}
+	}()
+
+	fn()
+}
+
+// sendMail отправляет письмо в фоне, не задерживая ответ клиенту
+func (app *application) sendMail(recipient, templateFile string, data any) {
+	app.background(func() {
+		err := app.mailer.Send(recipient, templateFile, data)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+}
+
 func (app *application) parseTimeStringLegacy(input string) (time.Time, error) {
 	if len(input) != len("2006-01-02-15-04-05") {
 		return time.Time{}, errors.New("incorrect time format")
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/olzzhas/narxozer/internal/image"
+	"net/http"
+	"strings"
+)
+
+// imageHandler перенаправляет постоянную ссылку на изображение на
+// подписанную ссылку хранилища с коротким сроком действия
+func (app *application) imageHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("key"), "/")
+	// Архивы с данными отдаются только по ссылке из письма, а исходные
+	// загрузки не проверены и не должны раздаваться вовсе
+	if !image.ValidKey(key) || strings.HasPrefix(key, "exports/") || strings.HasPrefix(key, "incoming/") {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	url, err := app.storages.Images.SignedURL(r.Context(), key, app.config.storage.urlTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Кешировать редирект можно только пока жива подписанная ссылка
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	http.Redirect(w, r, url, http.StatusFound)
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"cloud.google.com/go/storage"
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
@@ -13,12 +16,14 @@ import (
 	"github.com/olzzhas/narxozer/auth"
 	"github.com/olzzhas/narxozer/graph"
 	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/image"
 	"github.com/olzzhas/narxozer/internal/jsonlog"
 	"github.com/olzzhas/narxozer/internal/mailer"
 	"log"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -28,7 +33,9 @@ const version = "1.0.0"
 type config struct {
 	port int
 	env  string
-	db   struct {
+	// baseURL — внешний адрес API, из него строятся ссылки на файлы
+	baseURL string
+	db      struct {
 		dsn          string
 		maxOpenConns int
 		maxIdleConns int
@@ -48,18 +55,57 @@ type config struct {
 		password string
 		sender   string
 	}
+
+	ranking struct {
+		interval time.Duration
+	}
+
+	accounts struct {
+		// jobInterval — как часто собираются архивы с данными и удаляются
+		// аккаунты, срок отмены удаления которых истек
+		jobInterval time.Duration
+	}
+
+	jwt struct {
+		keysDir     string
+		activeKeyID string
+		issuer      string
+		audience    string
+	}
+
+	// Вход через университетский SSO; выключен, если не задан issuer
+	oidc auth.OIDCConfig
+
+	mfa struct {
+		// encryptionKey — ключ AES-256 в base64 для секретов TOTP
+		encryptionKey string
+	}
+
+	storage struct {
+		backend  string
+		bucket   string
+		localDir string
+		s3       image.S3Config
+		// urlTTL — срок действия подписанных ссылок на скачивание
+		urlTTL time.Duration
+		// signingKey — ключ HMAC в base64 для ссылок локального хранилища
+		signingKey string
+	}
 }
 
 type application struct {
 	config     config
 	logger     *jsonlog.Logger
 	jwtManager *auth.JWTManager
+	auth       *auth.Service
 	models     data.Models
 	resolver   *graph.Resolver
 	redis      *redis.Client
 	storages   data.Storages
 	mailer     mailer.Mailer
 	wg         sync.WaitGroup
+	// stopJobs останавливает периодические задачи при graceful shutdown
+	stopJobs context.CancelFunc
 }
 
 func main() {
@@ -77,14 +123,17 @@ func main() {
 
 	flag.IntVar(&cfg.port, "port", PORT, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "development|staging|production")
+	flag.StringVar(&cfg.baseURL, "base-url", envOr("BASE_URL", "http://localhost:4000"), "Public base URL of the API")
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("DB_DSN"), "PostgreSQL DSN")
 
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
 
-	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 50000, "Rate limiter maximum requests per second")
-	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
+	// Лимит на IP: за одним NAT университета может сидеть много студентов,
+	// поэтому он мягкий; перебор паролей ограничивается отдельно (см. auth.checkThrottle)
+	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 10, "Rate limiter maximum requests per second")
+	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 20, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
 	smtpPort, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
@@ -95,10 +144,46 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", os.Getenv("SMTP_SENDER"), "SMTP sender")
 
+	flag.StringVar(&cfg.jwt.keysDir, "jwt-keys-dir", os.Getenv("JWT_KEYS_DIR"), "Directory with JWT signing keys named <kid>.pem")
+	flag.StringVar(&cfg.jwt.activeKeyID, "jwt-active-kid", os.Getenv("JWT_ACTIVE_KID"), "Key ID used to sign new tokens")
+	flag.StringVar(&cfg.jwt.issuer, "jwt-issuer", "narxozer", "JWT issuer (iss)")
+	flag.StringVar(&cfg.jwt.audience, "jwt-audience", "narxozer-api", "JWT audience (aud)")
+
+	flag.StringVar(&cfg.oidc.IssuerURL, "oidc-issuer", os.Getenv("OIDC_ISSUER_URL"), "OpenID Connect issuer URL of the university identity provider")
+	flag.StringVar(&cfg.oidc.ClientID, "oidc-client-id", os.Getenv("OIDC_CLIENT_ID"), "OpenID Connect client ID")
+	flag.StringVar(&cfg.oidc.ClientSecret, "oidc-client-secret", os.Getenv("OIDC_CLIENT_SECRET"), "OpenID Connect client secret (empty for a public client)")
+	flag.StringVar(&cfg.oidc.RedirectURL, "oidc-redirect-url", os.Getenv("OIDC_REDIRECT_URL"), "OpenID Connect redirect URL pointing at /v1/oidc/callback")
+	flag.StringVar(&cfg.oidc.FacultyClaim, "oidc-faculty-claim", "faculty", "ID token claim holding the user's faculty")
+	flag.StringVar(&cfg.oidc.AffiliationClaim, "oidc-affiliation-claim", "affiliation", "ID token claim holding the user's affiliation (student, faculty, ...)")
+
+	flag.StringVar(&cfg.mfa.encryptionKey, "mfa-encryption-key", os.Getenv("MFA_ENCRYPTION_KEY"), "Base64-encoded 32-byte key for encrypting TOTP secrets")
+
+	flag.StringVar(&cfg.storage.backend, "storage-backend", envOr("STORAGE_BACKEND", image.BackendLocal), "File storage backend (gcs|s3|local)")
+	flag.StringVar(&cfg.storage.bucket, "storage-bucket", os.Getenv("STORAGE_BUCKET"), "Bucket for the gcs and s3 backends")
+	flag.StringVar(&cfg.storage.localDir, "storage-local-dir", envOr("STORAGE_LOCAL_DIR", "./uploads"), "Directory for the local backend")
+	flag.StringVar(&cfg.storage.s3.Endpoint, "storage-s3-endpoint", os.Getenv("STORAGE_S3_ENDPOINT"), "S3-compatible endpoint (host:port)")
+	flag.StringVar(&cfg.storage.s3.Region, "storage-s3-region", os.Getenv("STORAGE_S3_REGION"), "S3 region")
+	flag.StringVar(&cfg.storage.s3.AccessKey, "storage-s3-access-key", os.Getenv("STORAGE_S3_ACCESS_KEY"), "S3 access key")
+	flag.StringVar(&cfg.storage.s3.SecretKey, "storage-s3-secret-key", os.Getenv("STORAGE_S3_SECRET_KEY"), "S3 secret key")
+	flag.BoolVar(&cfg.storage.s3.UseSSL, "storage-s3-use-ssl", os.Getenv("STORAGE_S3_USE_SSL") != "false", "Use HTTPS for the S3 endpoint")
+	flag.DurationVar(&cfg.storage.urlTTL, "storage-url-ttl", 15*time.Minute, "Lifetime of signed download URLs")
+	flag.StringVar(&cfg.storage.signingKey, "storage-signing-key", os.Getenv("STORAGE_SIGNING_KEY"), "Base64-encoded HMAC key for local storage URLs (random if empty)")
+
+	flag.DurationVar(&cfg.ranking.interval, "ranking-interval", time.Minute, "Hot ranking refresh interval")
+	flag.DurationVar(&cfg.accounts.jobInterval, "account-jobs-interval", time.Minute, "Data export and account deletion processing interval")
+
 	flag.Parse()
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
+	if cfg.ranking.interval <= 0 {
+		logger.PrintFatal(errors.New("-ranking-interval must be positive"), nil)
+	}
+
+	if cfg.accounts.jobInterval <= 0 {
+		logger.PrintFatal(errors.New("-account-jobs-interval must be positive"), nil)
+	}
+
 	// Redis
 
 	redisClient, err := redisConnect()
@@ -108,26 +193,15 @@ func main() {
 
 	logger.PrintInfo("redis connection established", nil)
 
-	// Google Storage
-
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	// Файловое хранилище
 
-	storageClient, err := storage.NewClient(ctx)
+	images, closeStorage, err := openStorage(cfg)
 	if err != nil {
-		// log.Fatalf("failed to create client: %v", err)
-		logger.PrintInfo("google storage connection failed", nil)
+		logger.PrintFatal(fmt.Errorf("opening %s storage: %w", cfg.storage.backend, err), nil)
 	}
+	defer closeStorage()
 
-	logger.PrintInfo("google storage connection established", nil)
-
-	defer func(storageClient *storage.Client) {
-		err := storageClient.Close()
-		if err != nil {
-
-		}
-	}(storageClient)
+	logger.PrintInfo("file storage ready", map[string]string{"backend": cfg.storage.backend})
 
 	// PostgreSQL
 
@@ -161,24 +235,58 @@ func main() {
 		return time.Now().Unix()
 	}))
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		logger.PrintFatal(fmt.Errorf("jwt secret isn't provided"), nil)
+	// Ключи подписи: активный подписывает новые токены, остальные
+	// остаются в каталоге на время ротации только для проверки
+	jwtKeys, err := auth.LoadKeys(cfg.jwt.keysDir)
+	if err != nil {
+		logger.PrintFatal(fmt.Errorf("loading jwt keys: %w", err), nil)
+	}
+
+	jwtManager, err := auth.NewJWTManager(jwtKeys, cfg.jwt.activeKeyID, cfg.jwt.issuer, cfg.jwt.audience, 15*time.Minute)
+	if err != nil {
+		logger.PrintFatal(err, nil)
 	}
 
-	jwtManager := auth.NewJWTManager(jwtSecret, 24*time.Hour)
+	models := data.NewModels(db, redisClient)
 
 	app := &application{
 		config:     cfg,
 		logger:     logger,
 		jwtManager: jwtManager,
-		models:     data.NewModels(db, redisClient),
-		resolver:   graph.NewResolver(data.NewModels(db, redisClient), logger),
-		storages:   data.NewStorages(storageClient),
+		models:     models,
+		storages:   data.NewStorages(images, cfg.baseURL),
 		redis:      redisClient,
 		mailer:     mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
 	}
 
+	mfaKey, err := base64.StdEncoding.DecodeString(cfg.mfa.encryptionKey)
+	if err != nil {
+		logger.PrintFatal(fmt.Errorf("decoding mfa encryption key: %w", err), nil)
+	}
+
+	app.auth, err = auth.NewService(models, jwtManager, app.sendMail, mfaKey)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if cfg.oidc.IssuerURL != "" {
+		oidcProvider, err := auth.NewOIDCProvider(context.Background(), cfg.oidc)
+		if err != nil {
+			logger.PrintFatal(fmt.Errorf("oidc discovery: %w", err), nil)
+		}
+
+		app.auth.EnableOIDC(oidcProvider)
+		logger.PrintInfo("oidc login enabled", map[string]string{"issuer": cfg.oidc.IssuerURL})
+	}
+
+	app.resolver = graph.NewResolver(models, app.storages, logger, app.auth)
+
+	var jobsCtx context.Context
+	jobsCtx, app.stopJobs = context.WithCancel(context.Background())
+
+	app.refreshRankings(jobsCtx)
+	app.runAccountJobs(jobsCtx)
+
 	err = app.serve()
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -211,6 +319,79 @@ func openDB(cfg config) (*sql.DB, error) {
 	return db, nil
 }
 
+// openStorage создает выбранный конфигурацией бэкенд. Ошибка фатальна:
+// раньше сервер запускался с пустым клиентом GCS и падал на первой загрузке.
+func openStorage(cfg config) (image.Storage, func(), error) {
+	noop := func() {}
+
+	switch cfg.storage.backend {
+	case image.BackendGCS:
+		if cfg.storage.bucket == "" {
+			return nil, nil, fmt.Errorf("storage-bucket is required")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return image.GCSStorage{Client: client, Bucket: cfg.storage.bucket}, func() { client.Close() }, nil
+
+	case image.BackendS3:
+		if cfg.storage.bucket == "" || cfg.storage.s3.Endpoint == "" {
+			return nil, nil, fmt.Errorf("storage-bucket and storage-s3-endpoint are required")
+		}
+
+		s3cfg := cfg.storage.s3
+		s3cfg.Bucket = cfg.storage.bucket
+
+		st, err := image.NewS3Storage(s3cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return st, noop, nil
+
+	case image.BackendLocal:
+		key, err := base64.StdEncoding.DecodeString(cfg.storage.signingKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding storage signing key: %w", err)
+		}
+
+		// Без ключа ссылки перестанут работать после перезапуска, для
+		// разработки этого достаточно
+		if len(key) == 0 {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		st := image.LocalStorage{
+			Dir:        cfg.storage.localDir,
+			BaseURL:    strings.TrimSuffix(cfg.baseURL, "/") + "/v1/files",
+			SigningKey: key,
+		}
+
+		return st, noop, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", cfg.storage.backend)
+	}
+}
+
+// envOr возвращает переменную окружения или значение по умолчанию
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
 // TODO add to config
 func redisConnect() (*redis.Client, error) {
 	redisClient := redis.NewClient(&redis.Options{
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"expvar"
 	"fmt"
 	"github.com/felixge/httpsnoop"
 	"github.com/olzzhas/narxozer/auth"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/internal/data"
 	"github.com/tomasen/realip"
 	"golang.org/x/time/rate"
 	"net/http"
@@ -153,11 +156,16 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 	return app.requireAuthenticatedUser(fn)
 }
 
+// requirePermission пропускает запрос, только если у пользователя есть право
+// code. Должен стоять после authenticate.
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		user := app.contextGetUser(r)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if middleware.GetUserIDFromContext(r.Context()) == 0 {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
 
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		permissions, err := middleware.GetPermissionsFromContext(r.Context())
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
@@ -170,8 +178,6 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 
 		next.ServeHTTP(w, r)
 	}
-
-	return app.requireActivatedUser(fn)
 }
 
 func (app *application) metrics(next http.Handler) http.Handler {
@@ -192,23 +198,95 @@ func (app *application) metrics(next http.Handler) http.Handler {
 	})
 }
 
+// authenticate определяет пользователя по Bearer токену. Токен необязателен:
+// без заголовка Authorization запрос выполняется анонимно, а вот неверный
+// или отозванный токен отклоняется ошибкой UNAUTHENTICATED.
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			app.invalidAuthenticationTokenResponse(w, r)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		headerParts := strings.Split(authHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.unauthenticatedGraphQLResponse(w, r)
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := app.jwtManager.Verify(tokenString)
+		if strings.HasPrefix(headerParts[1], data.PersonalAccessTokenPrefix) {
+			app.authenticatePersonalAccessToken(w, r, next, headerParts[1])
+			return
+		}
+
+		claims, err := app.jwtManager.Verify(headerParts[1])
+		if err != nil || claims.SessionID == "" {
+			app.unauthenticatedGraphQLResponse(w, r)
+			return
+		}
+
+		// Access токен отозванной сессии (logout, кража refresh токена) больше не принимается
+		revoked, err := app.models.AuthorizationTokens.IsRevoked(claims.SessionID)
 		if err != nil {
-			app.invalidAuthenticationTokenResponse(w, r)
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if revoked {
+			app.unauthenticatedGraphQLResponse(w, r)
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), auth.ContextUserID, claims.UserID)
 		ctx = context.WithValue(ctx, auth.ContextUserRole, claims.Role)
+		ctx = context.WithValue(ctx, auth.ContextSessionID, claims.SessionID)
+		ctx = context.WithValue(ctx, auth.ContextMFA, claims.MFA)
+		ctx = middleware.WithPermissionLoader(ctx, func() (data.Permissions, error) {
+			return app.models.Permissions.GetCachedForUser(claims.UserID)
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticatePersonalAccessToken пропускает запрос от имени владельца
+// персонального токена. Сессии у такого запроса нет, а мутации ограничены
+// областями токена (см. директиву @scope).
+func (app *application) authenticatePersonalAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, plaintext string) {
+	token, err := app.models.PersonalAccessTokens.Authenticate(plaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.unauthenticatedGraphQLResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), auth.ContextUserID, token.UserID)
+	ctx = context.WithValue(ctx, auth.ContextUserRole, token.UserRole.String())
+	ctx = context.WithValue(ctx, auth.ContextTokenScopes, token.Scopes)
+	ctx = middleware.WithPermissionLoader(ctx, func() (data.Permissions, error) {
+		return app.models.Permissions.GetCachedForUser(token.UserID)
+	})
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// identifyClient сохраняет IP и User-Agent клиента в контексте запроса;
+// они записываются в сессию при входе и обновлении токенов
+func (app *application) identifyClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := auth.ClientInfo{
+			IPAddress: realip.FromRequest(r),
+			UserAgent: r.UserAgent(),
+		}
+
+		ctx := context.WithValue(r.Context(), auth.ContextClientInfo, client)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
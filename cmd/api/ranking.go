@@ -0,0 +1,17 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// refreshRankings периодически пересчитывает hot-рейтинг топиков и постов
+// в Redis, пока не отменен ctx.
+func (app *application) refreshRankings(ctx context.Context) {
+	app.every(ctx, app.config.ranking.interval, func() {
+		err := app.models.Rankings.Refresh()
+		if err != nil {
+			app.logger.PrintError(fmt.Errorf("error while refreshing rankings: %v", err), nil)
+		}
+	})
+}
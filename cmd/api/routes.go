@@ -2,41 +2,74 @@ package main
 
 import (
 	"expvar"
-	"fmt"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/julienschmidt/httprouter"
 	"github.com/olzzhas/narxozer/graph/generated"
-	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/internal/image"
 	"net/http"
+	"time"
+)
+
+const (
+	// maxUploadSize ограничивает весь multipart-запрос GraphQL вместе с файлами
+	maxUploadSize = 10 << 20
+	// maxUploadMemory — сколько файла держать в памяти, остальное пишется во временный файл
+	maxUploadMemory = 2 << 20
 )
 
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
-	// all methods
-	protected := app.authenticate(handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: app.resolver})))
+	schema := generated.NewExecutableSchema(generated.Config{
+		Resolvers:  app.resolver,
+		Directives: app.resolver.Directives(),
+	})
 
-	router.Handler(http.MethodPost, "/protected", protected)
+	// То же, что handler.NewDefaultServer, но с ограничением размера
+	// multipart-запросов с файлами
+	srv := handler.New(schema)
+	srv.AddTransport(transport.Websocket{KeepAlivePingInterval: 10 * time.Second})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: maxUploadSize,
+		MaxMemory:     maxUploadMemory,
+	})
+	srv.SetQueryCache(lru.New(1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New(100)})
+	srv.AroundFields(app.resolver.TokenScopeMiddleware)
 
-	router.Handler(http.MethodPost, "/query", handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: app.resolver})))
+	// Единственная точка входа GraphQL; токен необязателен
+	router.Handler(http.MethodPost, "/query", app.authenticate(srv))
 	router.Handler(http.MethodGet, "/", playground.Handler("GraphQL playground", "/query"))
 
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
 	router.HandlerFunc(http.MethodPost, "/v1/login", app.loginUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/login/mfa", app.verifyMFAHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/register", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/refresh", app.refreshTokenHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/oidc/login", app.oidcLoginHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/oidc/callback", app.oidcCallbackHandler)
 
-	//return app.metrics(app.recoverPanic(app.rateLimit(router)))
-	return app.metrics(app.recoverPanic(app.rateLimit(router)))
+	router.HandlerFunc(http.MethodGet, "/.well-known/jwks.json", app.jwksHandler)
 
-}
+	router.HandlerFunc(http.MethodGet, "/v1/images/*key", app.imageHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/exports/:token", app.dataExportHandler)
+
+	// Локальное хранилище само раздает файлы по подписанным ссылкам
+	if local, ok := app.storages.Images.(image.LocalStorage); ok {
+		router.Handler(http.MethodGet, "/v1/files/*key", http.StripPrefix("/v1/files", local))
+		router.Handler(http.MethodPut, "/v1/files/*key", http.StripPrefix("/v1/files", local))
+	}
 
-func ProtectedHandler(w http.ResponseWriter, r *http.Request) {
-	// Извлечение UserID и Role из контекста
-	userID := middleware.GetUserIDFromContext(r.Context())
-	userRole := middleware.GetUserRoleFromContext(r.Context())
+	//return app.metrics(app.recoverPanic(app.rateLimit(router)))
+	return app.metrics(app.recoverPanic(app.rateLimit(app.identifyClient(router))))
 
-	// Обработка запроса
-	w.Write([]byte("User ID: " + fmt.Sprint(userID) + " Role: " + userRole))
 }
@@ -46,6 +46,8 @@ func (app *application) serve() error {
 			"addr": srv.Addr,
 		})
 
+		app.stopJobs()
+
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"time"
+)
+
+// ExportMyData is the resolver for the exportMyData field.
+func (r *mutationResolver) ExportMyData(ctx context.Context) (*model.DataExport, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	// Ссылка на архив уходит на email, поэтому он должен быть подтвержден
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	export, err := r.Models.DataExports.New(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrExportTooSoon):
+			return nil, gqlerror.Errorf("a data export was already requested in the last 24 hours")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while creating data export: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return dataExport(export), nil
+}
+
+// RequestAccountDeletion is the resolver for the requestAccountDeletion field.
+func (r *mutationResolver) RequestAccountDeletion(ctx context.Context) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Auth.RequestAccountDeletion(userID)
+	if err != nil {
+		return false, r.authError(validator.New(), err)
+	}
+
+	return true, nil
+}
+
+// DeleteMyAccount is the resolver for the deleteMyAccount field.
+func (r *mutationResolver) DeleteMyAccount(ctx context.Context, token string) (string, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	v := validator.New()
+
+	scheduledAt, err := r.Auth.DeleteAccount(v, userID, token)
+	if err != nil {
+		return "", r.authError(v, err)
+	}
+
+	return scheduledAt.Format(time.RFC3339), nil
+}
+
+// CancelAccountDeletion is the resolver for the cancelAccountDeletion field.
+func (r *mutationResolver) CancelAccountDeletion(ctx context.Context) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.Users.CancelDeletion(int(userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, gqlerror.Errorf("account deletion is not scheduled")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while cancelling account deletion: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return true, nil
+}
+
+// MyDataExports is the resolver for the myDataExports field.
+func (r *queryResolver) MyDataExports(ctx context.Context) ([]*model.DataExport, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	exports, err := r.Models.DataExports.GetAllForUser(userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting data exports: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	result := make([]*model.DataExport, len(exports))
+	for i, export := range exports {
+		result[i] = dataExport(export)
+	}
+
+	return result, nil
+}
+
+func dataExport(export *data.DataExport) *model.DataExport {
+	result := &model.DataExport{
+		ID:        int(export.ID),
+		CreatedAt: export.CreatedAt.Format(time.RFC3339),
+	}
+
+	switch export.Status {
+	case data.DataExportStatusReady:
+		result.Status = model.DataExportStatusReady
+	case data.DataExportStatusFailed:
+		result.Status = model.DataExportStatusFailed
+	default:
+		result.Status = model.DataExportStatusPending
+	}
+
+	if export.ExpiresAt != nil {
+		expiresAt := export.ExpiresAt.Format(time.RFC3339)
+		result.ExpiresAt = &expiresAt
+	}
+
+	return result
+}
@@ -1,108 +1,256 @@
 package graph
 
-//
-//import (
-//	"context"
-//	"errors"
-//	"github.com/olzzhas/narxozer/graph/model"
-//	"golang.org/x/crypto/bcrypt"
-//)
-//
-//// Register is the resolver for the register field.
-//func (r *mutationResolver) Register(ctx context.Context, input model.RegisterInput) (*model.AuthPayload, error) {
-//	// Проверка, существует ли пользователь с данным email
-//	existingUser, err := r.Models.Users.GetByEmail(input.Email)
-//	if err == nil && existingUser != nil {
-//		return nil, errors.New("email already in use")
-//	}
-//
-//	// Хэширование пароля
-//	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-//	if err != nil {
-//		return nil, errors.New("failed to hash password")
-//	}
-//
-//	// Создание нового пользователя
-//	user := &model.User{
-//		Email:        input.Email,
-//		Name:         input.Name,
-//		Lastname:     input.Lastname,
-//		PasswordHash: string(hashedPassword),
-//		Role:         "STUDENT",
-//	}
-//
-//	// Сохранение пользователя в базе данных
-//	err = r.Models.Users.Insert(user)
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	// Генерация JWT токенов
-//	accessToken, err := r.JWTManager.Generate(int64(user.ID), user.Role.String())
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	refreshToken, err := r.JWTManager.GenerateRefresh(int64(user.ID), user.Role.String())
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	return &model.AuthPayload{
-//		AccessToken:  accessToken,
-//		RefreshToken: refreshToken,
-//	}, nil
-//
-//}
-//
-//// Login is the resolver for the login field.
-//func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*model.AuthPayload, error) {
-//	// Найти пользователя в базе данных по email
-//	user, err := r.Models.Users.GetByEmail(email)
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	// Проверка пароля
-//	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	// Генерация токенов
-//	accessToken, err := r.JWTManager.Generate(int64(user.ID), user.Role.String())
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	refreshToken, err := r.JWTManager.GenerateRefresh(int64(user.ID), user.Role.String())
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	return &model.AuthPayload{
-//		AccessToken:  accessToken,
-//		RefreshToken: refreshToken,
-//	}, nil
-//
-//}
-//
-//// RefreshToken is the resolver for the refreshToken field.
-//func (r *mutationResolver) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthPayload, error) {
-//	// Верификация Refresh токена
-//	claims, err := r.JWTManager.Verify(refreshToken)
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	// Генерация нового Access токена
-//	accessToken, err := r.JWTManager.Generate(claims.UserID, claims.Role)
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	return &model.AuthPayload{
-//		AccessToken:  accessToken,
-//		RefreshToken: refreshToken, // Используем старый Refresh токен
-//	}, nil
-//}
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/auth"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"time"
+)
+
+// Register is the resolver for the register field.
+func (r *mutationResolver) Register(ctx context.Context, input model.RegisterInput) (*model.AuthPayload, error) {
+	v := validator.New()
+
+	session, err := r.Auth.Register(v, input, middleware.GetClientInfoFromContext(ctx))
+	if err != nil {
+		return nil, r.authError(v, err)
+	}
+
+	return authPayload(session), nil
+}
+
+// Login is the resolver for the login field.
+func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*model.AuthPayload, error) {
+	v := validator.New()
+
+	session, err := r.Auth.Login(v, email, password, middleware.GetClientInfoFromContext(ctx))
+	if err != nil {
+		return nil, r.authError(v, err)
+	}
+
+	return authPayload(session), nil
+}
+
+// RefreshToken is the resolver for the refreshToken field.
+func (r *mutationResolver) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthPayload, error) {
+	session, err := r.Auth.Refresh(refreshToken, middleware.GetClientInfoFromContext(ctx))
+	if err != nil {
+		return nil, r.authError(validator.New(), err)
+	}
+
+	return authPayload(session), nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	_, err := r.Models.AuthorizationTokens.RevokeSession(userID, middleware.GetSessionIDFromContext(ctx))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while revoking session: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	return true, nil
+}
+
+// LogoutAllDevices is the resolver for the logoutAllDevices field.
+func (r *mutationResolver) LogoutAllDevices(ctx context.Context) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.AuthorizationTokens.RevokeAllForUser(userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while revoking sessions: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	return true, nil
+}
+
+// RevokeSession is the resolver for the revokeSession field.
+func (r *mutationResolver) RevokeSession(ctx context.Context, id string) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	revoked, err := r.Models.AuthorizationTokens.RevokeSession(userID, id)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while revoking session: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	if !revoked {
+		return false, gqlerror.Errorf("session not found")
+	}
+
+	return true, nil
+}
+
+// MySessions is the resolver for the mySessions field.
+func (r *queryResolver) MySessions(ctx context.Context) ([]*model.Session, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	sessions, err := r.Models.AuthorizationTokens.GetAllForUser(userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting sessions: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	currentSessionID := middleware.GetSessionIDFromContext(ctx)
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+// ActivateAccount is the resolver for the activateAccount field.
+func (r *mutationResolver) ActivateAccount(ctx context.Context, token string) (*model.User, error) {
+	v := validator.New()
+
+	user, err := r.Auth.Activate(v, token)
+	if err != nil {
+		return nil, r.authError(v, err)
+	}
+
+	// Токен активации подтверждает, что профиль запрашивает его владелец
+	user.IsViewer = true
+
+	return user, nil
+}
+
+// ResendActivation is the resolver for the resendActivation field.
+func (r *mutationResolver) ResendActivation(ctx context.Context) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Auth.ResendActivation(userID)
+	if err != nil {
+		return false, r.authError(validator.New(), err)
+	}
+
+	return true, nil
+}
+
+// RequestPasswordReset is the resolver for the requestPasswordReset field.
+func (r *mutationResolver) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	v := validator.New()
+
+	err := r.Auth.RequestPasswordReset(v, email)
+	if err != nil {
+		return false, r.authError(v, err)
+	}
+
+	return true, nil
+}
+
+// ResetPassword is the resolver for the resetPassword field.
+func (r *mutationResolver) ResetPassword(ctx context.Context, token string, newPassword string) (bool, error) {
+	v := validator.New()
+
+	err := r.Auth.ResetPassword(v, token, newPassword)
+	if err != nil {
+		return false, r.authError(v, err)
+	}
+
+	return true, nil
+}
+
+// ChangePassword is the resolver for the changePassword field.
+func (r *mutationResolver) ChangePassword(ctx context.Context, oldPassword string, newPassword string) (*model.AuthPayload, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	v := validator.New()
+
+	session, err := r.Auth.ChangePassword(v, userID, oldPassword, newPassword, middleware.GetClientInfoFromContext(ctx), middleware.GetMFAFromContext(ctx))
+	if err != nil {
+		return nil, r.authError(v, err)
+	}
+
+	return authPayload(session), nil
+}
+
+func (r *mutationResolver) authError(v *validator.Validator, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrFailedValidation):
+		return failedValidationError(v)
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		return gqlerror.Errorf("invalid authentication credentials")
+	case errors.Is(err, auth.ErrAlreadyActivated):
+		return gqlerror.Errorf("account is already activated")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return tooManyAttemptsError(err)
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		return gqlerror.Errorf("two-factor authentication is already enabled")
+	case errors.Is(err, auth.ErrMFANotEnabled):
+		return gqlerror.Errorf("two-factor authentication is not enabled")
+	default:
+		r.Logger.PrintError(fmt.Errorf("error while authenticating: %v", err), nil)
+		return gqlerror.Errorf("internal server error")
+	}
+}
+
+func authPayload(session *auth.Session) *model.AuthPayload {
+	if session.MFAChallenge != nil {
+		return &model.AuthPayload{
+			MfaChallenge: &model.MFAChallenge{
+				Token:     session.MFAChallenge.Token,
+				ExpiresAt: session.MFAChallenge.ExpiresAt.Format(time.RFC3339),
+			},
+		}
+	}
+
+	// Запрос входа еще анонимный, но профиль в ответе принадлежит
+	// вошедшему пользователю
+	if session.User != nil {
+		session.User.IsViewer = true
+	}
+
+	return &model.AuthPayload{
+		AccessToken:           &session.AccessToken,
+		RefreshToken:          &session.RefreshToken,
+		User:                  session.User,
+		MfaEnrollmentRequired: session.MFAEnrollmentRequired,
+	}
+}
+
+// UnlockAccount is the resolver for the unlockAccount field.
+func (r *mutationResolver) UnlockAccount(ctx context.Context, userID int) (bool, error) {
+	err := r.Auth.UnlockAccount(int64(userID), middleware.GetClientInfoFromContext(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, gqlerror.Errorf("user not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while unlocking account: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return true, nil
+}
+
+// SecurityEvents is the resolver for the securityEvents field.
+func (r *queryResolver) SecurityEvents(ctx context.Context, userID int, limit *int) ([]*model.SecurityEvent, error) {
+	n := 50
+	if limit != nil {
+		n = *limit
+	}
+
+	v := validator.New()
+	v.Check(n >= 1 && n <= 500, "limit", "must be between 1 and 500")
+	if !v.Valid() {
+		return nil, failedValidationError(v)
+	}
+
+	events, err := r.Models.SecurityEvents.GetAllForUser(int64(userID), n)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting security events: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return events, nil
+}
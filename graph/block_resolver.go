@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// BlockUser is the resolver for the blockUser field.
+func (r *mutationResolver) BlockUser(ctx context.Context, userID int) (bool, error) {
+	blockerID := middleware.GetUserIDFromContext(ctx)
+
+	if userID == int(blockerID) {
+		return false, gqlerror.Errorf("you cannot block yourself")
+	}
+
+	err := r.Models.Blocks.Block(int(blockerID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, gqlerror.Errorf("user not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while blocking user: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return true, nil
+}
+
+// UnblockUser is the resolver for the unblockUser field.
+func (r *mutationResolver) UnblockUser(ctx context.Context, userID int) (bool, error) {
+	blockerID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.Blocks.Unblock(int(blockerID), userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while unblocking user: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	return true, nil
+}
+
+// MuteUser is the resolver for the muteUser field.
+func (r *mutationResolver) MuteUser(ctx context.Context, userID int) (bool, error) {
+	muterID := middleware.GetUserIDFromContext(ctx)
+
+	if userID == int(muterID) {
+		return false, gqlerror.Errorf("you cannot mute yourself")
+	}
+
+	err := r.Models.Blocks.Mute(int(muterID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, gqlerror.Errorf("user not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while muting user: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return true, nil
+}
+
+// UnmuteUser is the resolver for the unmuteUser field.
+func (r *mutationResolver) UnmuteUser(ctx context.Context, userID int) (bool, error) {
+	muterID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.Blocks.Unmute(int(muterID), userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while unmuting user: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	return true, nil
+}
+
+// BlockedUsers is the resolver for the blockedUsers field.
+func (r *queryResolver) BlockedUsers(ctx context.Context) ([]*model.User, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	users, err := r.Models.Blocks.GetBlocked(int(userID))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting blocked users: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return users, nil
+}
+
+// MutedUsers is the resolver for the mutedUsers field.
+func (r *queryResolver) MutedUsers(ctx context.Context) ([]*model.User, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	users, err := r.Models.Blocks.GetMuted(int(userID))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting muted users: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return users, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/olzzhas/narxozer/graph/middleware"
 	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 	"time"
 )
@@ -14,9 +15,6 @@ import (
 // JoinClub is the resolver for the joinClub field.
 func (r *mutationResolver) JoinClub(ctx context.Context, clubID int) (*model.Club, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
-	}
 
 	err := r.Models.Clubs.AddMember(clubID, int(userID))
 	if err != nil {
@@ -43,6 +41,12 @@ func (r *mutationResolver) JoinClub(ctx context.Context, clubID int) (*model.Clu
 		return nil, gqlerror.Errorf("internal server error")
 	}
 
+	// Состав клубов пользователя изменился — лента должна пересобраться
+	err = r.Models.Feed.Invalidate(int(userID))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while invalidating feed: %v", err), nil)
+	}
+
 	// Возвращаем обновленные данные о клубе
 	club, err := r.Models.Clubs.GetCachedByID(clubID)
 	if err != nil {
@@ -55,9 +59,6 @@ func (r *mutationResolver) JoinClub(ctx context.Context, clubID int) (*model.Clu
 
 func (r *mutationResolver) LeaveClub(ctx context.Context, clubID int) (*model.Club, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
-	}
 
 	success, err := r.Models.Clubs.RemoveMember(clubID, int(userID))
 	if err != nil {
@@ -88,6 +89,12 @@ func (r *mutationResolver) LeaveClub(ctx context.Context, clubID int) (*model.Cl
 		return nil, gqlerror.Errorf("internal server error")
 	}
 
+	// Состав клубов пользователя изменился — лента должна пересобраться
+	err = r.Models.Feed.Invalidate(int(userID))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while invalidating feed: %v", err), nil)
+	}
+
 	// Возвращаем обновленные данные о клубе
 	club, err := r.Models.Clubs.GetCachedByID(clubID)
 	if err != nil {
@@ -102,14 +109,20 @@ func (r *mutationResolver) LeaveClub(ctx context.Context, clubID int) (*model.Cl
 // CreateClub is the resolver for the createClub field.
 func (r *mutationResolver) CreateClub(ctx context.Context, input model.CreateClubInput) (*model.Club, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	imageURL, err := r.contentImageURL(ctx, userID, data.UploadKindClub, input.Image, input.ImageURL)
+	if err != nil {
+		return nil, err
 	}
 
 	club := &model.Club{
 		Name:        input.Name,
 		Description: input.Description,
-		ImageURL:    input.ImageURL,
+		ImageURL:    imageURL,
 		Creator:     &model.User{ID: int(userID)},
 	}
 
@@ -137,14 +150,12 @@ func (r *mutationResolver) CreateClub(ctx context.Context, input model.CreateClu
 // UpdateClub is the resolver for the updateClub field.
 func (r *mutationResolver) UpdateClub(ctx context.Context, id int, input model.UpdateClubInput) (*model.Club, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
-	}
 
-	isAdmin := r.Models.Clubs.IsAdmin(id, int(userID))
-	if !isAdmin {
-		return nil, errors.New("you do not have permission to update this club")
+	imageURL, err := r.attachedImageURL(userID, data.UploadKindClub, input.ImageURL)
+	if err != nil {
+		return nil, err
 	}
+	input.ImageURL = imageURL
 
 	club, err := r.Models.Clubs.Update(id, input)
 	if err != nil {
@@ -178,24 +189,6 @@ func (r *mutationResolver) UpdateClub(ctx context.Context, id int, input model.U
 
 // DeleteClub is the resolver for the deleteClub field.
 func (r *mutationResolver) DeleteClub(ctx context.Context, id int) (bool, error) {
-	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return false, errors.New("unauthorized")
-	}
-
-	isAdmin := r.Models.Clubs.IsAdmin(id, int(userID))
-
-	// Если пользователь не является администратором, проверяем, является ли он создателем клуба
-	if !isAdmin {
-		isCreator, err := r.Models.Clubs.IsCreator(id, int(userID))
-		if err != nil {
-			return false, err
-		}
-		if !isCreator {
-			return false, errors.New("you do not have permission to delete this club")
-		}
-	}
-
 	// Удаляем все связанные с клубом данные
 	err := r.Models.Clubs.DeleteAllRelatedData(id)
 	if err != nil {
@@ -212,18 +205,23 @@ func (r *mutationResolver) DeleteClub(ctx context.Context, id int) (bool, error)
 }
 
 // Clubs is the resolver for the clubs field.
-func (r *queryResolver) Clubs(ctx context.Context) ([]*model.Club, error) {
-	clubs, err := r.Models.Clubs.GetAll()
+func (r *queryResolver) Clubs(ctx context.Context, first *int, after *string, last *int, before *string) (*model.ClubConnection, error) {
+	filters, err := cursorFilters(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	clubs, err := r.Models.Clubs.GetAll(filters)
 	if err != nil {
 		return nil, err
 	}
-	for _, club := range clubs {
-		members, err := r.Models.Clubs.GetCachedMembers(club.ID)
+	for _, edge := range clubs.Edges {
+		members, err := r.Models.Clubs.GetCachedMembers(edge.Node.ID)
 		if err != nil {
 			r.Logger.PrintError(fmt.Errorf("error while getting club members: %v", err), nil)
 			return nil, gqlerror.Errorf("internal server error")
 		}
-		club.Members = members
+		edge.Node.Members = members
 	}
 
 	return clubs, nil
@@ -250,16 +248,6 @@ func (r *queryResolver) ClubByID(ctx context.Context, id int) (*model.Club, erro
 
 // AssignAdmin is the resolver for the assignAdmin field.
 func (r *mutationResolver) AssignAdmin(ctx context.Context, clubID int, userID int) (*model.Club, error) {
-	adminID := middleware.GetUserIDFromContext(ctx)
-	if adminID == 0 {
-		return nil, errors.New("unauthorized")
-	}
-
-	isAdmin := r.Models.Clubs.IsAdmin(clubID, int(adminID))
-	if !isAdmin {
-		return nil, errors.New("you do not have permission to assign admins for this club")
-	}
-
 	err := r.Models.Clubs.AddAdmin(clubID, userID)
 	if err != nil {
 		return nil, err
@@ -280,3 +268,8 @@ func (r *mutationResolver) AssignAdmin(ctx context.Context, clubID int, userID i
 
 	return club, nil
 }
+
+// ImageVariants is the resolver for the imageVariants field.
+func (r *clubResolver) ImageVariants(ctx context.Context, obj *model.Club) (*model.ImageVariants, error) {
+	return r.imageVariants(obj.ImageURL), nil
+}
@@ -6,28 +6,40 @@ import (
 	"fmt"
 	"github.com/olzzhas/narxozer/graph/middleware"
 	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 // CreateComment is the resolver for the createComment field.
 func (r *mutationResolver) CreateComment(ctx context.Context, input model.CreateCommentInput) (*model.Comment, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	imageURL, err := r.attachedImageURL(userID, data.UploadKindComment, input.ImageURL)
+	if err != nil {
+		return nil, err
 	}
 
 	comment := &model.Comment{
 		Content:    input.Content,
-		ImageURL:   input.ImageURL,
+		ImageURL:   imageURL,
 		EntityID:   input.EntityID,
 		EntityType: input.EntityType.String(),
 		Author:     &model.User{ID: int(userID)},
 		ParentID:   input.ParentID,
 	}
 
-	comment, err := r.Models.Comments.Insert(comment)
+	comment, err = r.Models.Comments.Insert(comment)
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, data.ErrBlocked):
+			return nil, blockedError()
+		default:
+			return nil, err
+		}
 	}
 
 	user, err := r.Models.Users.GetCached(comment.Author.ID)
@@ -44,22 +56,33 @@ func (r *mutationResolver) CreateComment(ctx context.Context, input model.Create
 // ReplyToComment is the resolver for the replyToComment field.
 func (r *mutationResolver) ReplyToComment(ctx context.Context, commentID int, input model.CreateCommentInput) (*model.Comment, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	imageURL, err := r.attachedImageURL(userID, data.UploadKindComment, input.ImageURL)
+	if err != nil {
+		return nil, err
 	}
 
 	comment := &model.Comment{
 		Content:    input.Content,
-		ImageURL:   input.ImageURL,
+		ImageURL:   imageURL,
 		EntityID:   input.EntityID,
 		EntityType: input.EntityType.String(),
 		Author:     &model.User{ID: int(userID)},
 		ParentID:   &commentID,
 	}
 
-	comment, err := r.Models.Comments.Insert(comment)
+	comment, err = r.Models.Comments.Insert(comment)
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, data.ErrBlocked):
+			return nil, blockedError()
+		default:
+			return nil, err
+		}
 	}
 
 	user, err := r.Models.Users.GetCached(comment.Author.ID)
@@ -74,86 +97,149 @@ func (r *mutationResolver) ReplyToComment(ctx context.Context, commentID int, in
 }
 
 // Comments is the resolver for the comments field.
-func (r *queryResolver) Comments(ctx context.Context, postID int) ([]*model.Comment, error) {
+func (r *queryResolver) Comments(ctx context.Context, postID int, first *int, after *string, last *int, before *string) (*model.CommentConnection, error) {
+	filters, err := cursorFilters(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	viewerID := middleware.GetUserIDFromContext(ctx)
 
 	// TODO redis
-	comments, err := r.Models.Comments.GetAllByPost(postID)
+	comments, err := r.Models.Comments.GetByEntityID(int(viewerID), postID, model.EntityTypePost.String(), filters)
 	if err != nil {
 		return nil, err
 	}
 
+	for _, edge := range comments.Edges {
+		user, err := r.Models.Users.GetCached(edge.Node.Author.ID)
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+		edge.Node.Author = user
+	}
+
 	return comments, nil
 }
 
 // LikeComment is the resolver for the likeComment field.
 func (r *mutationResolver) LikeComment(ctx context.Context, id int) (*model.Comment, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, fmt.Errorf("unauthorized")
+
+	err := r.Models.Comments.ToggleLike(int(userID), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, gqlerror.Errorf("comment not found")
+		case errors.Is(err, data.ErrBlocked):
+			return nil, blockedError()
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while liking comment: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
 	}
 
-	// Проверяем, не лайкнул ли уже этот пользователь данный комментарий
-	var existingLike int
-	err := r.Models.Posts.DB.QueryRow("SELECT COUNT(*) FROM likes WHERE user_id = $1 AND entity_id = $2 AND entity_type = 'comment'", userID, id).Scan(&existingLike)
+	// Возвращаем обновленный комментарий
+	comment, err := r.Models.Comments.GetByID(int(userID), id)
 	if err != nil {
-		return nil, err
+		r.Logger.PrintError(fmt.Errorf("error while getting comment: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
 	}
 
-	if existingLike > 0 {
-		// Если пользователь уже лайкнул комментарий, удаляем лайк (unlike)
-		_, err := r.Models.Posts.DB.Exec("DELETE FROM likes WHERE user_id = $1 AND entity_id = $2 AND entity_type = 'comment'", userID, id)
-		if err != nil {
-			return nil, fmt.Errorf("internal server error")
-		}
+	if comment == nil {
+		return nil, gqlerror.Errorf("comment not found")
+	}
 
-		// Уменьшаем счетчик лайков в таблице comments
-		_, err = r.Models.Posts.DB.Exec("UPDATE comments SET likes = likes - 1 WHERE id = $1", id)
-		if err != nil {
-			return nil, fmt.Errorf("internal server error")
-		}
+	user, err := r.Models.Users.GetCached(comment.Author.ID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	comment.Author = user
+
+	return comment, nil
+}
+
+// UpdateComment is the resolver for the updateComment field.
+func (r *mutationResolver) UpdateComment(ctx context.Context, id int, input model.UpdateCommentInput) (*model.Comment, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	comment, err := r.Models.Comments.GetByID(int(userID), id)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting comment: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	if comment == nil {
+		return nil, gqlerror.Errorf("comment not found")
+	}
 
-		// Возвращаем обновленный комментарий
-		comment := &model.Comment{}
-		err = r.Models.Posts.DB.QueryRow("SELECT id, content, created_at, likes FROM comments WHERE id = $1", id).Scan(
-			&comment.ID, &comment.Content, &comment.CreatedAt, &comment.Likes)
+	if comment.Author.ID != int(userID) {
+		return nil, gqlerror.Errorf("you have no permission to update this comment")
+	}
+
+	comment.Content = input.Content
+	if input.ImageURL != nil {
+		comment.ImageURL, err = r.attachedImageURL(userID, data.UploadKindComment, input.ImageURL)
 		if err != nil {
 			return nil, err
 		}
-
-		return comment, nil
 	}
 
-	// Добавляем лайк в таблицу likes
-	_, err = r.Models.Posts.DB.Exec("INSERT INTO likes (user_id, entity_id, entity_type) VALUES ($1, $2, 'comment')", userID, id)
+	err = r.Models.Comments.Update(comment)
 	if err != nil {
-		return nil, fmt.Errorf("internal server error")
+		r.Logger.PrintError(fmt.Errorf("error while updating comment: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
 	}
 
-	// Увеличиваем счетчик лайков в таблице comments
-	_, err = r.Models.Posts.DB.Exec("UPDATE comments SET likes = likes + 1 WHERE id = $1", id)
+	user, err := r.Models.Users.GetCached(comment.Author.ID)
 	if err != nil {
-		return nil, fmt.Errorf("internal server error")
+		r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
 	}
 
-	// Возвращаем обновленный комментарий
-	comment := &model.Comment{}
-	err = r.Models.Posts.DB.QueryRow("SELECT id, content, created_at, likes FROM comments WHERE id = $1", id).Scan(
-		&comment.ID, &comment.Content, &comment.CreatedAt, &comment.Likes)
-	if err != nil {
-		return nil, fmt.Errorf("internal server error")
-	}
+	comment.Author = user
 
 	return comment, nil
 }
 
-// UpdateComment is the resolver for the updateComment field.
-func (r *mutationResolver) UpdateComment(ctx context.Context, id int, input model.UpdateCommentInput) (*model.Comment, error) {
-	// TODO implement
-	return nil, nil
-}
-
 // DeleteComment is the resolver for the deleteComment field.
 func (r *mutationResolver) DeleteComment(ctx context.Context, id int) (bool, error) {
-	// TODO implement
-	return false, nil
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	// Блокировка не мешает модератору удалить комментарий, поэтому зритель анонимный
+	comment, err := r.Models.Comments.GetByID(0, id)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting comment: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	if comment == nil {
+		return false, gqlerror.Errorf("comment not found")
+	}
+
+	if comment.Author.ID != int(userID) {
+		canModerate, err := r.hasPermission(ctx, data.PermissionPostsModerate)
+		if err != nil {
+			return false, err
+		}
+
+		if !canModerate {
+			return false, gqlerror.Errorf("you have no permission to delete this comment")
+		}
+
+		if err := r.requireMFA(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	err = r.Models.Comments.Delete(id)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while deleting comment: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	return true, nil
 }
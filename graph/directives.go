@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/olzzhas/narxozer/graph/generated"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Старшинство ролей: роль выше удовлетворяет требованию любой роли ниже
+var (
+	roleRank = map[model.Role]int{
+		model.RoleStudent: 1,
+		model.RoleTeacher: 2,
+		model.RoleAdmin:   3,
+	}
+
+	clubRoleRank = map[model.ClubRole]int{
+		model.ClubRoleMember:  1,
+		model.ClubRoleAdmin:   2,
+		model.ClubRoleCreator: 3,
+	}
+)
+
+// Directives возвращает обработчики директив авторизации из schema.graphqls
+func (r *Resolver) Directives() generated.DirectiveRoot {
+	return generated.DirectiveRoot{
+		Auth:          r.authDirective,
+		HasRole:       r.hasRoleDirective,
+		ClubRole:      r.clubRoleDirective,
+		HasPermission: r.hasPermissionDirective,
+		Scope:         r.scopeDirective,
+	}
+}
+
+// authDirective реализует @auth
+func (r *Resolver) authDirective(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if middleware.GetUserIDFromContext(ctx) == 0 {
+		return nil, unauthenticatedError()
+	}
+
+	return next(ctx)
+}
+
+// hasRoleDirective реализует @hasRole(role:)
+func (r *Resolver) hasRoleDirective(ctx context.Context, obj interface{}, next graphql.Resolver, role model.Role) (interface{}, error) {
+	if middleware.GetUserIDFromContext(ctx) == 0 {
+		return nil, unauthenticatedError()
+	}
+
+	userRole := model.Role(middleware.GetUserRoleFromContext(ctx))
+	if roleRank[userRole] < roleRank[role] {
+		return nil, forbiddenError(fmt.Sprintf("this action requires the %s role", role))
+	}
+
+	if err := r.requireMFA(ctx); err != nil {
+		return nil, err
+	}
+
+	return next(ctx)
+}
+
+// clubRoleDirective реализует @clubRole(min:, arg:, of:). Id клуба или
+// события берется из аргумента поля с именем arg.
+func (r *Resolver) clubRoleDirective(ctx context.Context, obj interface{}, next graphql.Resolver, min model.ClubRole, arg string, of model.ClubRoleTarget) (interface{}, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == 0 {
+		return nil, unauthenticatedError()
+	}
+
+	clubID, ok := graphql.GetFieldContext(ctx).Args[arg].(int)
+	if !ok {
+		r.Logger.PrintError(fmt.Errorf("@clubRole: field has no int argument %q", arg), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	if of == model.ClubRoleTargetEvent {
+		event, err := r.Models.Events.GetByID(clubID)
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while getting event: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+
+		if event == nil {
+			return nil, gqlerror.Errorf("event not found")
+		}
+
+		clubID = event.ClubID
+	}
+
+	role, err := r.Models.Clubs.GetRole(clubID, int(userID))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting club role: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	if role == nil || clubRoleRank[*role] < clubRoleRank[min] {
+		return nil, forbiddenError("you do not have permission to manage this club")
+	}
+
+	return next(ctx)
+}
+
+// hasPermissionDirective реализует @hasPermission(code:)
+func (r *Resolver) hasPermissionDirective(ctx context.Context, obj interface{}, next graphql.Resolver, code string) (interface{}, error) {
+	if middleware.GetUserIDFromContext(ctx) == 0 {
+		return nil, unauthenticatedError()
+	}
+
+	ok, err := r.hasPermission(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, forbiddenError(fmt.Sprintf("this action requires the %s permission", code))
+	}
+
+	if err := r.requireMFA(ctx); err != nil {
+		return nil, err
+	}
+
+	return next(ctx)
+}
+
+// scopeDirective реализует @scope(name:): запрос с персональным токеном
+// должен иметь указанную область. Для JWT-сессий директива ничего не проверяет.
+func (r *Resolver) scopeDirective(ctx context.Context, obj interface{}, next graphql.Resolver, name string) (interface{}, error) {
+	scopes, ok := middleware.GetTokenScopesFromContext(ctx)
+	if ok && !validator.PermittedValue(name, scopes...) {
+		return nil, forbiddenError(fmt.Sprintf("this action requires the %s token scope", name))
+	}
+
+	return next(ctx)
+}
+
+// TokenScopeMiddleware запрещает персональным токенам мутации без @scope:
+// вход, смену пароля, управление правами и самими токенами
+func (r *Resolver) TokenScopeMiddleware(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+
+	if fc.Object == "Mutation" && fc.IsResolver {
+		if _, ok := middleware.GetTokenScopesFromContext(ctx); ok && fc.Field.Definition.Directives.ForName("scope") == nil {
+			return nil, forbiddenError("this action is not available to personal access tokens")
+		}
+	}
+
+	return next(ctx)
+}
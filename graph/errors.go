@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"errors"
+	"github.com/olzzhas/narxozer/auth"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// failedValidationError превращает ошибки валидатора в GraphQL-ошибку,
+// сохраняя сообщения по каждому полю в extensions.
+func failedValidationError(v *validator.Validator) error {
+	return &gqlerror.Error{
+		Message: "validation failed",
+		Extensions: map[string]interface{}{
+			"code":   "BAD_USER_INPUT",
+			"fields": v.Errors,
+		},
+	}
+}
+
+// unauthenticatedError возвращается, когда поле требует входа в систему.
+func unauthenticatedError() error {
+	return &gqlerror.Error{
+		Message: "unauthorized",
+		Extensions: map[string]interface{}{
+			"code": "UNAUTHENTICATED",
+		},
+	}
+}
+
+// forbiddenError возвращается, когда у пользователя недостаточно прав.
+func forbiddenError(message string) error {
+	return &gqlerror.Error{
+		Message: message,
+		Extensions: map[string]interface{}{
+			"code": "FORBIDDEN",
+		},
+	}
+}
+
+// inactiveAccountError возвращается, когда пользователь с неподтвержденным
+// email пытается создавать контент.
+func inactiveAccountError() error {
+	return forbiddenError("your user account must be activated to access this resource")
+}
+
+// blockedError возвращается, когда владелец контента заблокировал пользователя.
+func blockedError() error {
+	return forbiddenError("you cannot interact with this user")
+}
+
+// tooManyAttemptsError возвращается, когда вход временно запрещен после
+// серии неудачных попыток; retryAfter — пауза в секундах.
+func tooManyAttemptsError(err error) error {
+	extensions := map[string]interface{}{
+		"code": "TOO_MANY_REQUESTS",
+	}
+
+	var throttled *auth.ThrottledError
+	if errors.As(err, &throttled) {
+		extensions["retryAfter"] = throttled.RetryAfterSeconds()
+	}
+
+	return &gqlerror.Error{
+		Message:    "too many failed login attempts, please try again later",
+		Extensions: extensions,
+	}
+}
@@ -2,31 +2,34 @@ package graph
 
 import (
 	"context"
-	"errors"
 	"github.com/olzzhas/narxozer/graph/middleware"
 	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 // CreateEvent is the resolver for the createEvent field.
 func (r *mutationResolver) CreateEvent(ctx context.Context, clubID int, input model.CreateEventInput) (*model.Event, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
 	}
 
-	if !r.Models.Clubs.IsAdmin(clubID, int(userID)) {
-		return nil, errors.New("unauthorized: only admins can create events")
+	imageURL, err := r.contentImageURL(ctx, userID, data.UploadKindEvent, input.Image, input.ImageURL)
+	if err != nil {
+		return nil, err
 	}
 
 	event := &model.Event{
 		Title:       input.Title,
 		Description: input.Description,
-		ImageURL:    input.ImageURL,
+		ImageURL:    imageURL,
 		Date:        input.Date,
 		ClubID:      clubID,
 	}
 
-	event, err := r.Models.Events.Insert(event)
+	event, err = r.Models.Events.Insert(event)
 	if err != nil {
 		return nil, err
 	}
@@ -37,18 +40,15 @@ func (r *mutationResolver) CreateEvent(ctx context.Context, clubID int, input mo
 // UpdateEvent is the resolver for the updateEvent field.
 func (r *mutationResolver) UpdateEvent(ctx context.Context, id int, input model.UpdateEventInput) (*model.Event, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
-	}
 
+	// Права администратора клуба проверяет @clubRole
 	event, err := r.Models.Events.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// Проверяем, является ли пользователь администратором клуба
-	if !r.Models.Clubs.IsAdmin(event.ClubID, int(userID)) {
-		return nil, errors.New("unauthorized: only admins can update events")
+	if event == nil {
+		return nil, gqlerror.Errorf("event not found")
 	}
 
 	// Обновляем поля мероприятия, если они были переданы в input
@@ -62,7 +62,10 @@ func (r *mutationResolver) UpdateEvent(ctx context.Context, id int, input model.
 		event.Date = *input.Date
 	}
 	if input.ImageURL != nil {
-		event.ImageURL = input.ImageURL
+		event.ImageURL, err = r.attachedImageURL(userID, data.UploadKindEvent, input.ImageURL)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	event, err = r.Models.Events.Update(event)
@@ -75,25 +78,16 @@ func (r *mutationResolver) UpdateEvent(ctx context.Context, id int, input model.
 
 // DeleteEvent is the resolver for the deleteEvent field.
 func (r *mutationResolver) DeleteEvent(ctx context.Context, id int) (bool, error) {
-	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return false, errors.New("unauthorized")
-	}
-
-	event, err := r.Models.Events.GetByID(id)
-	if err != nil {
-		return false, err
-	}
-
-	// Проверяем, является ли пользователь администратором клуба
-	if !r.Models.Clubs.IsAdmin(event.ClubID, int(userID)) {
-		return false, errors.New("unauthorized: only admins can delete events")
-	}
-
-	err = r.Models.Events.Delete(id)
+	// Права администратора клуба проверяет @clubRole
+	err := r.Models.Events.Delete(id)
 	if err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
+
+// ImageVariants is the resolver for the imageVariants field.
+func (r *eventResolver) ImageVariants(ctx context.Context, obj *model.Event) (*model.ImageVariants, error) {
+	return r.imageVariants(obj.ImageURL), nil
+}
@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"strings"
+)
+
+// Feed is the resolver for the feed field.
+func (r *queryResolver) Feed(ctx context.Context, first *int, after *string) (*model.FeedConnection, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	filters, err := cursorFilters(first, after, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items, pageInfo, totalCount, err := r.Models.Feed.Get(int(userID), filters)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting feed: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	edges := make([]*model.FeedEdge, 0, len(items))
+	for _, item := range items {
+		node, err := r.feedItemNode(int(userID), item)
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while loading feed item: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+
+		// Запись могли удалить, пока лента лежала в кеше
+		if node == nil {
+			continue
+		}
+
+		edges = append(edges, &model.FeedEdge{
+			Cursor: item.Cursor,
+			Kind:   model.FeedItemKind(strings.ToUpper(item.Kind)),
+			Node:   node,
+		})
+	}
+
+	return &model.FeedConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
+}
+
+// feedItemNode загружает запись ленты. Лента лежит в кеше, поэтому блокировки,
+// появившиеся после ее сборки, проверяются при загрузке.
+func (r *queryResolver) feedItemNode(viewerID int, item *data.FeedItem) (model.FeedItem, error) {
+	switch item.Kind {
+	case data.FeedKindFollowedPost, data.FeedKindClubAnnouncement:
+		post, err := r.Models.Posts.FindOne(viewerID, int64(item.ID))
+		if err != nil || post == nil {
+			return nil, err
+		}
+
+		post.Author, err = r.Models.Users.GetCached(post.Author.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return post, nil
+	case data.FeedKindClubEvent:
+		event, err := r.Models.Events.GetByID(item.ID)
+		if err != nil || event == nil {
+			return nil, err
+		}
+
+		return event, nil
+	case data.FeedKindHotTopic:
+		topic, err := r.Models.Topics.GetByID(viewerID, item.ID)
+		if err != nil || topic == nil {
+			return nil, err
+		}
+
+		topic.Author, err = r.Models.Users.GetCached(topic.Author.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return topic, nil
+	}
+
+	return nil, fmt.Errorf("unknown feed item kind %q", item.Kind)
+}
@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// FollowUser is the resolver for the followUser field.
+func (r *mutationResolver) FollowUser(ctx context.Context, userID int) (model.FollowStatus, error) {
+	followerID := middleware.GetUserIDFromContext(ctx)
+
+	if err := r.requireActivatedUser(followerID); err != nil {
+		return "", err
+	}
+
+	if userID == int(followerID) {
+		return "", gqlerror.Errorf("you cannot follow yourself")
+	}
+
+	status, err := r.Models.Follows.Follow(int(followerID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", gqlerror.Errorf("user not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while following user: %v", err), nil)
+			return "", gqlerror.Errorf("internal server error")
+		}
+	}
+
+	if status == data.FollowStatusPending {
+		return model.FollowStatusPending, nil
+	}
+
+	return model.FollowStatusAccepted, nil
+}
+
+// UnfollowUser is the resolver for the unfollowUser field.
+func (r *mutationResolver) UnfollowUser(ctx context.Context, userID int) (bool, error) {
+	followerID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.Follows.Unfollow(int(followerID), userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while unfollowing user: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	return true, nil
+}
+
+// ApproveFollowRequest is the resolver for the approveFollowRequest field.
+func (r *mutationResolver) ApproveFollowRequest(ctx context.Context, userID int) (bool, error) {
+	followeeID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.Follows.Approve(int(followeeID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, gqlerror.Errorf("follow request not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while approving follow request: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return true, nil
+}
+
+// RejectFollowRequest is the resolver for the rejectFollowRequest field.
+func (r *mutationResolver) RejectFollowRequest(ctx context.Context, userID int) (bool, error) {
+	followeeID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.Follows.Reject(int(followeeID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, gqlerror.Errorf("follow request not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while rejecting follow request: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return true, nil
+}
+
+// Followers is the resolver for the followers field.
+func (r *queryResolver) Followers(ctx context.Context, userID int, first *int, after *string, last *int, before *string) (*model.UserConnection, error) {
+	page, err := cursorFilters(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.requireFollowsVisible(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	followers, err := r.Models.Follows.GetFollowers(userID, page)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting followers: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return followers, nil
+}
+
+// Following is the resolver for the following field.
+func (r *queryResolver) Following(ctx context.Context, userID int, first *int, after *string, last *int, before *string) (*model.UserConnection, error) {
+	page, err := cursorFilters(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.requireFollowsVisible(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	following, err := r.Models.Follows.GetFollowing(userID, page)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting following: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return following, nil
+}
+
+// FollowRequests is the resolver for the followRequests field.
+func (r *queryResolver) FollowRequests(ctx context.Context, first *int, after *string, last *int, before *string) (*model.UserConnection, error) {
+	page, err := cursorFilters(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	requests, err := r.Models.Follows.GetRequests(int(userID), page)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting follow requests: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return requests, nil
+}
+
+// requireFollowsVisible скрывает подписчиков и подписки закрытого аккаунта
+// от всех, кроме владельца и его одобренных подписчиков, а любого аккаунта —
+// от заблокированных им
+func (r *Resolver) requireFollowsVisible(ctx context.Context, userID int) error {
+	user, err := r.Models.Users.Get(userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+		return gqlerror.Errorf("internal server error")
+	}
+
+	if user == nil {
+		return gqlerror.Errorf("user not found")
+	}
+
+	viewerID := int(middleware.GetUserIDFromContext(ctx))
+
+	blocked, err := r.Models.Blocks.IsBlocked(userID, viewerID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while checking block: %v", err), nil)
+		return gqlerror.Errorf("internal server error")
+	}
+
+	if blocked {
+		return gqlerror.Errorf("user not found")
+	}
+
+	if !user.RequiresFollowApproval || viewerID == userID {
+		return nil
+	}
+
+	if viewerID == 0 {
+		return forbiddenError("this account is private")
+	}
+
+	status, err := r.Models.Follows.Status(viewerID, userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting follow status: %v", err), nil)
+		return gqlerror.Errorf("internal server error")
+	}
+
+	if status != data.FollowStatusAccepted {
+		return forbiddenError("this account is private")
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func cursorFilters(first *int, after *string, last *int, before *string) (data.CursorFilters, error) {
+	filters := data.CursorFilters{
+		First:  first,
+		After:  after,
+		Last:   last,
+		Before: before,
+	}
+
+	v := validator.New()
+	if data.ValidateCursorFilters(v, filters); !v.Valid() {
+		return filters, failedValidationError(v)
+	}
+
+	return filters, nil
+}
+
+// rankingFilters дополнительно проверяет, что hot-рейтинг листают только
+// вперёд: он читается из sorted set Redis и не поддерживает last/before.
+func rankingFilters(sort *model.RankingSort, first *int, after *string, last *int, before *string) (model.RankingSort, data.CursorFilters, error) {
+	ranking := model.RankingSortNew
+	if sort != nil {
+		ranking = *sort
+	}
+
+	filters, err := cursorFilters(first, after, last, before)
+	if err != nil {
+		return ranking, filters, err
+	}
+
+	v := validator.New()
+	v.Check(ranking != model.RankingSortHot || (last == nil && before == nil), "sort", "HOT supports only first/after")
+	if !v.Valid() {
+		return ranking, filters, failedValidationError(v)
+	}
+
+	return ranking, filters, nil
+}
+
+// requireActivatedUser запрещает создавать контент, пока email не подтвержден
+func (r *Resolver) requireActivatedUser(userID int64) error {
+	user, err := r.Models.Users.GetCached(int(userID))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+		return gqlerror.Errorf("internal server error")
+	}
+
+	if user == nil || !user.Activated {
+		return inactiveAccountError()
+	}
+
+	return nil
+}
+
+// hasPermission проверяет право текущего пользователя. Права загружаются
+// один раз за запрос и кешируются в Redis.
+func (r *Resolver) hasPermission(ctx context.Context, code string) (bool, error) {
+	permissions, err := middleware.GetPermissionsFromContext(ctx)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting permissions: %v", err), nil)
+		return false, gqlerror.Errorf("internal server error")
+	}
+
+	return permissions.Include(code), nil
+}
+
+// requireMFA запрещает привилегированные действия сессиям без второго
+// фактора, если администратор сделал 2FA обязательной для роли пользователя
+func (r *Resolver) requireMFA(ctx context.Context) error {
+	if middleware.GetMFAFromContext(ctx) {
+		return nil
+	}
+
+	required, err := r.Models.MFA.IsRequiredForRole(model.Role(middleware.GetUserRoleFromContext(ctx)))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while checking mfa requirement: %v", err), nil)
+		return gqlerror.Errorf("internal server error")
+	}
+
+	if required {
+		return forbiddenError("two-factor authentication is required for your role")
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// VerifyMfa is the resolver for the verifyMFA field.
+func (r *mutationResolver) VerifyMfa(ctx context.Context, mfaToken string, code string) (*model.AuthPayload, error) {
+	v := validator.New()
+
+	session, err := r.Auth.VerifyMFA(v, mfaToken, code, middleware.GetClientInfoFromContext(ctx))
+	if err != nil {
+		return nil, r.authError(v, err)
+	}
+
+	return authPayload(session), nil
+}
+
+// EnrollTotp is the resolver for the enrollTOTP field.
+func (r *mutationResolver) EnrollTotp(ctx context.Context) (*model.TOTPEnrollment, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	enrollment, err := r.Auth.EnrollTOTP(userID)
+	if err != nil {
+		return nil, r.authError(validator.New(), err)
+	}
+
+	return &model.TOTPEnrollment{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	}, nil
+}
+
+// ConfirmTotp is the resolver for the confirmTOTP field.
+func (r *mutationResolver) ConfirmTotp(ctx context.Context, code string) ([]string, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	v := validator.New()
+
+	recoveryCodes, err := r.Auth.ConfirmTOTP(v, userID, code)
+	if err != nil {
+		return nil, r.authError(v, err)
+	}
+
+	return recoveryCodes, nil
+}
+
+// DisableTotp is the resolver for the disableTOTP field.
+func (r *mutationResolver) DisableTotp(ctx context.Context, code string) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	v := validator.New()
+
+	err := r.Auth.DisableTOTP(v, userID, code)
+	if err != nil {
+		return false, r.authError(v, err)
+	}
+
+	return true, nil
+}
+
+// RegenerateRecoveryCodes is the resolver for the regenerateRecoveryCodes field.
+func (r *mutationResolver) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	v := validator.New()
+
+	recoveryCodes, err := r.Auth.RegenerateRecoveryCodes(v, userID, code)
+	if err != nil {
+		return nil, r.authError(v, err)
+	}
+
+	return recoveryCodes, nil
+}
+
+// SetMFARequirement is the resolver for the setMFARequirement field.
+func (r *mutationResolver) SetMFARequirement(ctx context.Context, role model.Role, required bool) ([]model.Role, error) {
+	v := validator.New()
+	v.Check(role == model.RoleAdmin || role == model.RoleTeacher, "role", "2FA can only be required for ADMIN and TEACHER")
+	if !v.Valid() {
+		return nil, failedValidationError(v)
+	}
+
+	err := r.Models.MFA.SetRequiredForRole(role, required)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while setting mfa requirement: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return r.Query().MfaRequiredRoles(ctx)
+}
+
+// MfaRequiredRoles is the resolver for the mfaRequiredRoles field.
+func (r *queryResolver) MfaRequiredRoles(ctx context.Context) ([]model.Role, error) {
+	roles, err := r.Models.MFA.GetRequiredRoles()
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting mfa required roles: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return roles, nil
+}
@@ -3,35 +3,10 @@ package middleware
 import (
 	"context"
 	"github.com/olzzhas/narxozer/auth"
-	"net/http"
-	"strings"
+	"github.com/olzzhas/narxozer/internal/data"
+	"sync"
 )
 
-func AuthMiddleware(manager *auth.JWTManager) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
-				return
-			}
-
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := manager.Verify(tokenString)
-			if err != nil {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				return
-			}
-
-			// Добавляем UserID и Role в контекст запроса
-			ctx := context.WithValue(r.Context(), auth.ContextUserID, claims.UserID)
-			ctx = context.WithValue(ctx, auth.ContextUserRole, claims.Role)
-
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
-}
-
 func GetUserIDFromContext(ctx context.Context) int64 {
 	if userID, ok := ctx.Value(auth.ContextUserID).(int64); ok {
 		return userID
@@ -45,3 +20,56 @@ func GetUserRoleFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+func GetSessionIDFromContext(ctx context.Context) string {
+	if sessionID, ok := ctx.Value(auth.ContextSessionID).(string); ok {
+		return sessionID
+	}
+	return ""
+}
+
+// GetMFAFromContext сообщает, подтверждена ли текущая сессия вторым фактором
+func GetMFAFromContext(ctx context.Context) bool {
+	mfa, _ := ctx.Value(auth.ContextMFA).(bool)
+	return mfa
+}
+
+// GetTokenScopesFromContext возвращает области персонального токена доступа.
+// ok == false, если запрос аутентифицирован не персональным токеном.
+func GetTokenScopesFromContext(ctx context.Context) (scopes []string, ok bool) {
+	scopes, ok = ctx.Value(auth.ContextTokenScopes).([]string)
+	return scopes, ok
+}
+
+func GetClientInfoFromContext(ctx context.Context) auth.ClientInfo {
+	if client, ok := ctx.Value(auth.ContextClientInfo).(auth.ClientInfo); ok {
+		return client
+	}
+	return auth.ClientInfo{}
+}
+
+// WithPermissionLoader кладет в контекст ленивую загрузку прав пользователя:
+// права читаются не больше одного раза за запрос и только если понадобились
+func WithPermissionLoader(ctx context.Context, load func() (data.Permissions, error)) context.Context {
+	var (
+		once        sync.Once
+		permissions data.Permissions
+		err         error
+	)
+
+	loader := func() (data.Permissions, error) {
+		once.Do(func() {
+			permissions, err = load()
+		})
+		return permissions, err
+	}
+
+	return context.WithValue(ctx, auth.ContextPermissions, loader)
+}
+
+func GetPermissionsFromContext(ctx context.Context) (data.Permissions, error) {
+	if load, ok := ctx.Value(auth.ContextPermissions).(func() (data.Permissions, error)); ok {
+		return load()
+	}
+	return data.Permissions{}, nil
+}
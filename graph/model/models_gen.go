@@ -6,23 +6,50 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
 )
 
+type FeedItem interface {
+	IsFeedItem()
+}
+
+type SearchResult interface {
+	IsSearchResult()
+}
+
 type AuthPayload struct {
-	AccessToken  string `json:"accessToken"`
-	RefreshToken string `json:"refreshToken"`
+	AccessToken           *string       `json:"accessToken,omitempty"`
+	RefreshToken          *string       `json:"refreshToken,omitempty"`
+	User                  *User         `json:"user,omitempty"`
+	MfaChallenge          *MFAChallenge `json:"mfaChallenge,omitempty"`
+	MfaEnrollmentRequired bool          `json:"mfaEnrollmentRequired"`
 }
 
 type Club struct {
-	ID          int      `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	ImageURL    *string  `json:"imageURL,omitempty"`
-	Creator     *User    `json:"creator"`
-	CreatedAt   string   `json:"createdAt"`
-	Members     []*User  `json:"members"`
-	Events      []*Event `json:"events"`
-	Admins      []*User  `json:"admins"`
+	ID            int            `json:"id"`
+	Name          string         `json:"name"`
+	Description   string         `json:"description"`
+	ImageURL      *string        `json:"imageURL,omitempty"`
+	ImageVariants *ImageVariants `json:"imageVariants,omitempty"`
+	Creator       *User          `json:"creator"`
+	CreatedAt     string         `json:"createdAt"`
+	Members       []*User        `json:"members"`
+	Events        []*Event       `json:"events"`
+	Admins        []*User        `json:"admins"`
+}
+
+func (Club) IsSearchResult() {}
+
+type ClubConnection struct {
+	Edges      []*ClubEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type ClubEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Club  `json:"node"`
 }
 
 type Comment struct {
@@ -39,10 +66,29 @@ type Comment struct {
 	Replies    []*Comment `json:"replies"`
 }
 
+type CommentConnection struct {
+	Edges      []*CommentEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+type CommentEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Comment `json:"node"`
+}
+
+type ConfirmedUpload struct {
+	ID            string         `json:"id"`
+	Kind          UploadKind     `json:"kind"`
+	ImageURL      string         `json:"imageURL"`
+	ImageVariants *ImageVariants `json:"imageVariants"`
+}
+
 type CreateClubInput struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	ImageURL    *string `json:"imageURL,omitempty"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	ImageURL    *string         `json:"imageURL,omitempty"`
+	Image       *graphql.Upload `json:"image,omitempty"`
 }
 
 type CreateCommentInput struct {
@@ -55,23 +101,33 @@ type CreateCommentInput struct {
 }
 
 type CreateEventInput struct {
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	ImageURL    *string `json:"imageURL,omitempty"`
-	Date        string  `json:"date"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	ImageURL    *string         `json:"imageURL,omitempty"`
+	Image       *graphql.Upload `json:"image,omitempty"`
+	Date        string          `json:"date"`
+}
+
+type CreatePersonalAccessTokenInput struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
 }
 
 type CreatePostInput struct {
-	Title    string  `json:"title"`
-	Content  string  `json:"content"`
-	ImageURL *string `json:"imageURL,omitempty"`
-	AuthorID int     `json:"authorId"`
+	Title    string          `json:"title"`
+	Content  string          `json:"content"`
+	ImageURL *string         `json:"imageURL,omitempty"`
+	Image    *graphql.Upload `json:"image,omitempty"`
+	AuthorID int             `json:"authorId"`
+	ClubID   *int            `json:"clubId,omitempty"`
 }
 
 type CreateTopicInput struct {
-	Title    string  `json:"title"`
-	Content  string  `json:"content"`
-	ImageURL *string `json:"imageURL,omitempty"`
+	Title    string          `json:"title"`
+	Content  string          `json:"content"`
+	ImageURL *string         `json:"imageURL,omitempty"`
+	Image    *graphql.Upload `json:"image,omitempty"`
 }
 
 type CreateUserInput struct {
@@ -88,51 +144,193 @@ type CreateUserInput struct {
 	Faculty               *string `json:"faculty,omitempty"`
 }
 
+type CreatedPersonalAccessToken struct {
+	Token               string               `json:"token"`
+	PersonalAccessToken *PersonalAccessToken `json:"personalAccessToken"`
+}
+
+type DataExport struct {
+	ID        int              `json:"id"`
+	Status    DataExportStatus `json:"status"`
+	CreatedAt string           `json:"createdAt"`
+	ExpiresAt *string          `json:"expiresAt,omitempty"`
+}
+
 type Event struct {
-	ID          int     `json:"id"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	ImageURL    *string `json:"imageURL,omitempty"`
-	CreatedAt   string  `json:"createdAt"`
-	Date        string  `json:"date"`
-	ClubID      int     `json:"clubId"`
+	ID            int            `json:"id"`
+	Title         string         `json:"title"`
+	Description   string         `json:"description"`
+	ImageURL      *string        `json:"imageURL,omitempty"`
+	ImageVariants *ImageVariants `json:"imageVariants,omitempty"`
+	CreatedAt     string         `json:"createdAt"`
+	Date          string         `json:"date"`
+	ClubID        int            `json:"clubId"`
+}
+
+func (Event) IsSearchResult() {}
+
+func (Event) IsFeedItem() {}
+
+type FeedConnection struct {
+	Edges      []*FeedEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type FeedEdge struct {
+	Cursor string       `json:"cursor"`
+	Kind   FeedItemKind `json:"kind"`
+	Node   FeedItem     `json:"node"`
+}
+
+type ImageVariants struct {
+	Small  string `json:"small"`
+	Medium string `json:"medium"`
+	Large  string `json:"large"`
+}
+
+type MFAChallenge struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
 }
 
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
+type PersonalAccessToken struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"createdAt"`
+	ExpiresAt  string   `json:"expiresAt"`
+	LastUsedAt *string  `json:"lastUsedAt,omitempty"`
+}
+
 type Post struct {
-	ID        int        `json:"id"`
-	Title     string     `json:"title"`
-	Content   string     `json:"content"`
-	ImageURL  *string    `json:"imageURL,omitempty"`
-	Author    *User      `json:"author"`
-	CreatedAt string     `json:"createdAt"`
-	UpdatedAt *string    `json:"updatedAt,omitempty"`
-	Likes     int        `json:"likes"`
-	Comments  []*Comment `json:"comments"`
+	ID            int            `json:"id"`
+	Title         string         `json:"title"`
+	Content       string         `json:"content"`
+	ImageURL      *string        `json:"imageURL,omitempty"`
+	ImageVariants *ImageVariants `json:"imageVariants,omitempty"`
+	Author        *User          `json:"author"`
+	ClubID        *int           `json:"clubId,omitempty"`
+	CreatedAt     string         `json:"createdAt"`
+	UpdatedAt     *string        `json:"updatedAt,omitempty"`
+	Likes         int            `json:"likes"`
+	CommentsCount int            `json:"commentsCount"`
+	Comments      []*Comment     `json:"comments"`
+}
+
+func (Post) IsSearchResult() {}
+
+func (Post) IsFeedItem() {}
+
+type PostConnection struct {
+	Edges      []*PostEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
+}
+
+type PrivacySettings struct {
+	Email                 ProfileVisibility `json:"email"`
+	Course                ProfileVisibility `json:"course"`
+	Major                 ProfileVisibility `json:"major"`
+	Faculty               ProfileVisibility `json:"faculty"`
+	AdditionalInformation ProfileVisibility `json:"additionalInformation"`
 }
 
 type Query struct {
 }
 
 type RegisterInput struct {
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Lastname string `json:"lastname"`
-	Password string `json:"password"`
+	Email                 string  `json:"email"`
+	Name                  string  `json:"name"`
+	Lastname              string  `json:"lastname"`
+	Password              string  `json:"password"`
+	ImageURL              *string `json:"imageURL,omitempty"`
+	AdditionalInformation *string `json:"additionalInformation,omitempty"`
+	Course                *int    `json:"course,omitempty"`
+	Major                 *string `json:"major,omitempty"`
+	Degree                *string `json:"degree,omitempty"`
+	Faculty               *string `json:"faculty,omitempty"`
+}
+
+type SearchConnection struct {
+	Edges      []*SearchEdge `json:"edges"`
+	PageInfo   *PageInfo     `json:"pageInfo"`
+	TotalCount int           `json:"totalCount"`
+}
+
+type SearchEdge struct {
+	Cursor  string       `json:"cursor"`
+	Node    SearchResult `json:"node"`
+	Rank    float64      `json:"rank"`
+	Snippet string       `json:"snippet"`
+}
+
+type SecurityEvent struct {
+	ID        int     `json:"id"`
+	Type      string  `json:"type"`
+	Email     string  `json:"email"`
+	IPAddress *string `json:"ipAddress,omitempty"`
+	UserAgent *string `json:"userAgent,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+type Session struct {
+	ID         string  `json:"id"`
+	UserAgent  *string `json:"userAgent,omitempty"`
+	IPAddress  *string `json:"ipAddress,omitempty"`
+	CreatedAt  string  `json:"createdAt"`
+	LastSeenAt string  `json:"lastSeenAt"`
+	ExpiresAt  string  `json:"expiresAt"`
+	Current    bool    `json:"current"`
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningURI"`
 }
 
 type Topic struct {
-	ID        int        `json:"id"`
-	Title     string     `json:"title"`
-	Content   string     `json:"content"`
-	ImageURL  *string    `json:"imageURL,omitempty"`
-	Author    *User      `json:"author"`
-	CreatedAt string     `json:"createdAt"`
-	UpdatedAt *string    `json:"updatedAt,omitempty"`
-	Likes     int        `json:"likes"`
-	Comments  []*Comment `json:"comments"`
+	ID            int            `json:"id"`
+	Title         string         `json:"title"`
+	Content       string         `json:"content"`
+	ImageURL      *string        `json:"imageURL,omitempty"`
+	ImageVariants *ImageVariants `json:"imageVariants,omitempty"`
+	Author        *User          `json:"author"`
+	CreatedAt     string         `json:"createdAt"`
+	UpdatedAt     *string        `json:"updatedAt,omitempty"`
+	Likes         int            `json:"likes"`
+	CommentsCount int            `json:"commentsCount"`
+	Comments      []*Comment     `json:"comments"`
+}
+
+func (Topic) IsSearchResult() {}
+
+func (Topic) IsFeedItem() {}
+
+type TopicConnection struct {
+	Edges      []*TopicEdge `json:"edges"`
+	PageInfo   *PageInfo    `json:"pageInfo"`
+	TotalCount int          `json:"totalCount"`
+}
+
+type TopicEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Topic `json:"node"`
 }
 
 type UpdateClubInput struct {
@@ -161,6 +359,14 @@ type UpdatePostInput struct {
 	ImageURL *string `json:"imageURL,omitempty"`
 }
 
+type UpdatePrivacySettingsInput struct {
+	Email                 *ProfileVisibility `json:"email,omitempty"`
+	Course                *ProfileVisibility `json:"course,omitempty"`
+	Major                 *ProfileVisibility `json:"major,omitempty"`
+	Faculty               *ProfileVisibility `json:"faculty,omitempty"`
+	AdditionalInformation *ProfileVisibility `json:"additionalInformation,omitempty"`
+}
+
 type UpdateTopicInput struct {
 	Title    *string `json:"title,omitempty"`
 	Content  *string `json:"content,omitempty"`
@@ -168,34 +374,170 @@ type UpdateTopicInput struct {
 }
 
 type UpdateUserInput struct {
-	Email                 *string `json:"email,omitempty"`
-	Name                  *string `json:"name,omitempty"`
-	Lastname              *string `json:"lastname,omitempty"`
-	Password              *string `json:"password,omitempty"`
-	Role                  *Role   `json:"role,omitempty"`
-	ImageURL              *string `json:"imageURL,omitempty"`
-	AdditionalInformation *string `json:"additionalInformation,omitempty"`
-	Course                *int    `json:"course,omitempty"`
-	Major                 *string `json:"major,omitempty"`
-	Degree                *string `json:"degree,omitempty"`
-	Faculty               *string `json:"faculty,omitempty"`
+	Email                  *string `json:"email,omitempty"`
+	Name                   *string `json:"name,omitempty"`
+	Lastname               *string `json:"lastname,omitempty"`
+	Role                   *Role   `json:"role,omitempty"`
+	ImageURL               *string `json:"imageURL,omitempty"`
+	AdditionalInformation  *string `json:"additionalInformation,omitempty"`
+	Course                 *int    `json:"course,omitempty"`
+	Major                  *string `json:"major,omitempty"`
+	Degree                 *string `json:"degree,omitempty"`
+	Faculty                *string `json:"faculty,omitempty"`
+	RequiresFollowApproval *bool   `json:"requiresFollowApproval,omitempty"`
 }
 
-type User struct {
-	ID                    int     `json:"id"`
-	Email                 string  `json:"email"`
-	Name                  string  `json:"name"`
-	Lastname              string  `json:"lastname"`
-	PasswordHash          string  `json:"passwordHash"`
-	Role                  Role    `json:"role"`
-	ImageURL              *string `json:"imageURL,omitempty"`
-	AdditionalInformation *string `json:"additionalInformation,omitempty"`
-	Course                *int    `json:"course,omitempty"`
-	CreatedAt             string  `json:"createdAt"`
-	UpdatedAt             *string `json:"updatedAt,omitempty"`
-	Major                 *string `json:"major,omitempty"`
-	Degree                *string `json:"degree,omitempty"`
-	Faculty               *string `json:"faculty,omitempty"`
+type UploadTicket struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+type UserConnection struct {
+	Edges      []*UserEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
+}
+
+type UserFilter struct {
+	Faculty      *string `json:"faculty,omitempty"`
+	Major        *string `json:"major,omitempty"`
+	Course       *int    `json:"course,omitempty"`
+	Degree       *string `json:"degree,omitempty"`
+	Role         *Role   `json:"role,omitempty"`
+	NameContains *string `json:"nameContains,omitempty"`
+}
+
+type ClubRole string
+
+const (
+	ClubRoleMember  ClubRole = "MEMBER"
+	ClubRoleAdmin   ClubRole = "ADMIN"
+	ClubRoleCreator ClubRole = "CREATOR"
+)
+
+var AllClubRole = []ClubRole{
+	ClubRoleMember,
+	ClubRoleAdmin,
+	ClubRoleCreator,
+}
+
+func (e ClubRole) IsValid() bool {
+	switch e {
+	case ClubRoleMember, ClubRoleAdmin, ClubRoleCreator:
+		return true
+	}
+	return false
+}
+
+func (e ClubRole) String() string {
+	return string(e)
+}
+
+func (e *ClubRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ClubRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ClubRole", str)
+	}
+	return nil
+}
+
+func (e ClubRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ClubRoleTarget string
+
+const (
+	ClubRoleTargetClub  ClubRoleTarget = "CLUB"
+	ClubRoleTargetEvent ClubRoleTarget = "EVENT"
+)
+
+var AllClubRoleTarget = []ClubRoleTarget{
+	ClubRoleTargetClub,
+	ClubRoleTargetEvent,
+}
+
+func (e ClubRoleTarget) IsValid() bool {
+	switch e {
+	case ClubRoleTargetClub, ClubRoleTargetEvent:
+		return true
+	}
+	return false
+}
+
+func (e ClubRoleTarget) String() string {
+	return string(e)
+}
+
+func (e *ClubRoleTarget) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ClubRoleTarget(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ClubRoleTarget", str)
+	}
+	return nil
+}
+
+func (e ClubRoleTarget) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DataExportStatus string
+
+const (
+	DataExportStatusPending DataExportStatus = "PENDING"
+	DataExportStatusReady   DataExportStatus = "READY"
+	DataExportStatusFailed  DataExportStatus = "FAILED"
+)
+
+var AllDataExportStatus = []DataExportStatus{
+	DataExportStatusPending,
+	DataExportStatusReady,
+	DataExportStatusFailed,
+}
+
+func (e DataExportStatus) IsValid() bool {
+	switch e {
+	case DataExportStatusPending, DataExportStatusReady, DataExportStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e DataExportStatus) String() string {
+	return string(e)
+}
+
+func (e *DataExportStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DataExportStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DataExportStatus", str)
+	}
+	return nil
+}
+
+func (e DataExportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
 type EntityType string
@@ -241,6 +583,182 @@ func (e EntityType) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+type FeedItemKind string
+
+const (
+	FeedItemKindFollowedPost     FeedItemKind = "FOLLOWED_POST"
+	FeedItemKindClubAnnouncement FeedItemKind = "CLUB_ANNOUNCEMENT"
+	FeedItemKindClubEvent        FeedItemKind = "CLUB_EVENT"
+	FeedItemKindHotTopic         FeedItemKind = "HOT_TOPIC"
+)
+
+var AllFeedItemKind = []FeedItemKind{
+	FeedItemKindFollowedPost,
+	FeedItemKindClubAnnouncement,
+	FeedItemKindClubEvent,
+	FeedItemKindHotTopic,
+}
+
+func (e FeedItemKind) IsValid() bool {
+	switch e {
+	case FeedItemKindFollowedPost, FeedItemKindClubAnnouncement, FeedItemKindClubEvent, FeedItemKindHotTopic:
+		return true
+	}
+	return false
+}
+
+func (e FeedItemKind) String() string {
+	return string(e)
+}
+
+func (e *FeedItemKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FeedItemKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FeedItemKind", str)
+	}
+	return nil
+}
+
+func (e FeedItemKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type FollowStatus string
+
+const (
+	FollowStatusAccepted FollowStatus = "ACCEPTED"
+	FollowStatusPending  FollowStatus = "PENDING"
+)
+
+var AllFollowStatus = []FollowStatus{
+	FollowStatusAccepted,
+	FollowStatusPending,
+}
+
+func (e FollowStatus) IsValid() bool {
+	switch e {
+	case FollowStatusAccepted, FollowStatusPending:
+		return true
+	}
+	return false
+}
+
+func (e FollowStatus) String() string {
+	return string(e)
+}
+
+func (e *FollowStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FollowStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FollowStatus", str)
+	}
+	return nil
+}
+
+func (e FollowStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ProfileVisibility string
+
+const (
+	ProfileVisibilityEveryone   ProfileVisibility = "EVERYONE"
+	ProfileVisibilityUniversity ProfileVisibility = "UNIVERSITY"
+	ProfileVisibilityFollowers  ProfileVisibility = "FOLLOWERS"
+	ProfileVisibilityNobody     ProfileVisibility = "NOBODY"
+)
+
+var AllProfileVisibility = []ProfileVisibility{
+	ProfileVisibilityEveryone,
+	ProfileVisibilityUniversity,
+	ProfileVisibilityFollowers,
+	ProfileVisibilityNobody,
+}
+
+func (e ProfileVisibility) IsValid() bool {
+	switch e {
+	case ProfileVisibilityEveryone, ProfileVisibilityUniversity, ProfileVisibilityFollowers, ProfileVisibilityNobody:
+		return true
+	}
+	return false
+}
+
+func (e ProfileVisibility) String() string {
+	return string(e)
+}
+
+func (e *ProfileVisibility) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ProfileVisibility(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ProfileVisibility", str)
+	}
+	return nil
+}
+
+func (e ProfileVisibility) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type RankingSort string
+
+const (
+	RankingSortHot           RankingSort = "HOT"
+	RankingSortTopWeek       RankingSort = "TOP_WEEK"
+	RankingSortNew           RankingSort = "NEW"
+	RankingSortMostCommented RankingSort = "MOST_COMMENTED"
+)
+
+var AllRankingSort = []RankingSort{
+	RankingSortHot,
+	RankingSortTopWeek,
+	RankingSortNew,
+	RankingSortMostCommented,
+}
+
+func (e RankingSort) IsValid() bool {
+	switch e {
+	case RankingSortHot, RankingSortTopWeek, RankingSortNew, RankingSortMostCommented:
+		return true
+	}
+	return false
+}
+
+func (e RankingSort) String() string {
+	return string(e)
+}
+
+func (e *RankingSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RankingSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RankingSort", str)
+	}
+	return nil
+}
+
+func (e RankingSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 type Role string
 
 const (
@@ -283,3 +801,99 @@ func (e *Role) UnmarshalGQL(v interface{}) error {
 func (e Role) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+type SearchType string
+
+const (
+	SearchTypePost  SearchType = "POST"
+	SearchTypeTopic SearchType = "TOPIC"
+	SearchTypeClub  SearchType = "CLUB"
+	SearchTypeEvent SearchType = "EVENT"
+	SearchTypeUser  SearchType = "USER"
+)
+
+var AllSearchType = []SearchType{
+	SearchTypePost,
+	SearchTypeTopic,
+	SearchTypeClub,
+	SearchTypeEvent,
+	SearchTypeUser,
+}
+
+func (e SearchType) IsValid() bool {
+	switch e {
+	case SearchTypePost, SearchTypeTopic, SearchTypeClub, SearchTypeEvent, SearchTypeUser:
+		return true
+	}
+	return false
+}
+
+func (e SearchType) String() string {
+	return string(e)
+}
+
+func (e *SearchType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SearchType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SearchType", str)
+	}
+	return nil
+}
+
+func (e SearchType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type UploadKind string
+
+const (
+	UploadKindPost    UploadKind = "POST"
+	UploadKindTopic   UploadKind = "TOPIC"
+	UploadKindClub    UploadKind = "CLUB"
+	UploadKindEvent   UploadKind = "EVENT"
+	UploadKindComment UploadKind = "COMMENT"
+	UploadKindAvatar  UploadKind = "AVATAR"
+)
+
+var AllUploadKind = []UploadKind{
+	UploadKindPost,
+	UploadKindTopic,
+	UploadKindClub,
+	UploadKindEvent,
+	UploadKindComment,
+	UploadKindAvatar,
+}
+
+func (e UploadKind) IsValid() bool {
+	switch e {
+	case UploadKindPost, UploadKindTopic, UploadKindClub, UploadKindEvent, UploadKindComment, UploadKindAvatar:
+		return true
+	}
+	return false
+}
+
+func (e UploadKind) String() string {
+	return string(e)
+}
+
+func (e *UploadKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UploadKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UploadKind", str)
+	}
+	return nil
+}
+
+func (e UploadKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
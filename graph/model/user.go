@@ -0,0 +1,31 @@
+package model
+
+// User описан вручную, чтобы хеш пароля и настройки приватности не попадали
+// в API напрямую. Поля с настройками приватности отдаются через резолверы,
+// а PasswordHash не сериализуется даже в кеш.
+type User struct {
+	ID                     int             `json:"id"`
+	Email                  string          `json:"email"`
+	Name                   string          `json:"name"`
+	Lastname               string          `json:"lastname"`
+	PasswordHash           string          `json:"-"`
+	Role                   Role            `json:"role"`
+	ImageURL               *string         `json:"imageURL,omitempty"`
+	AdditionalInformation  *string         `json:"additionalInformation,omitempty"`
+	Course                 *int            `json:"course,omitempty"`
+	CreatedAt              string          `json:"createdAt"`
+	UpdatedAt              *string         `json:"updatedAt,omitempty"`
+	Major                  *string         `json:"major,omitempty"`
+	Degree                 *string         `json:"degree,omitempty"`
+	Faculty                *string         `json:"faculty,omitempty"`
+	Activated              bool            `json:"activated"`
+	RequiresFollowApproval bool            `json:"requiresFollowApproval"`
+	FollowersCount         int             `json:"followersCount"`
+	FollowingCount         int             `json:"followingCount"`
+	Privacy                PrivacySettings `json:"privacy"`
+	// IsViewer отмечает профиль, выданный самому пользователю при входе или
+	// регистрации, когда запрос еще анонимный
+	IsViewer bool `json:"-"`
+}
+
+func (User) IsSearchResult() {}
@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// GrantPermission is the resolver for the grantPermission field.
+func (r *mutationResolver) GrantPermission(ctx context.Context, userID int, code string) ([]string, error) {
+	err := r.Models.Permissions.AddForUser(int64(userID), code)
+	if err != nil {
+		return nil, r.permissionError(err)
+	}
+
+	return r.userPermissions(int64(userID))
+}
+
+// RevokePermission is the resolver for the revokePermission field.
+func (r *mutationResolver) RevokePermission(ctx context.Context, userID int, code string) ([]string, error) {
+	err := r.Models.Permissions.RevokeForUser(int64(userID), code)
+	if err != nil {
+		return nil, r.permissionError(err)
+	}
+
+	return r.userPermissions(int64(userID))
+}
+
+// MyPermissions is the resolver for the myPermissions field.
+func (r *queryResolver) MyPermissions(ctx context.Context) ([]string, error) {
+	permissions, err := middleware.GetPermissionsFromContext(ctx)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting permissions: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return permissions, nil
+}
+
+func (r *mutationResolver) userPermissions(userID int64) ([]string, error) {
+	permissions, err := r.Models.Permissions.GetCachedForUser(userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting permissions: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return permissions, nil
+}
+
+func (r *mutationResolver) permissionError(err error) error {
+	switch {
+	case errors.Is(err, data.ErrUnknownPermission):
+		return gqlerror.Errorf("unknown permission")
+	case errors.Is(err, data.ErrRecordNotFound):
+		return gqlerror.Errorf("user not found")
+	}
+
+	r.Logger.PrintError(fmt.Errorf("error while updating permissions: %v", err), nil)
+	return gqlerror.Errorf("internal server error")
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"strings"
+	"time"
+)
+
+// CreatePersonalAccessToken is the resolver for the createPersonalAccessToken field.
+func (r *mutationResolver) CreatePersonalAccessToken(ctx context.Context, input model.CreatePersonalAccessTokenInput) (*model.CreatedPersonalAccessToken, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	v := validator.New()
+	if data.ValidatePersonalAccessTokenInput(v, input); !v.Valid() {
+		return nil, failedValidationError(v)
+	}
+
+	ttl := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+
+	token, err := r.Models.PersonalAccessTokens.New(userID, strings.TrimSpace(input.Name), input.Scopes, ttl)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while creating personal access token: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return &model.CreatedPersonalAccessToken{
+		Token:               token.Plaintext,
+		PersonalAccessToken: personalAccessToken(token),
+	}, nil
+}
+
+// RevokePersonalAccessToken is the resolver for the revokePersonalAccessToken field.
+func (r *mutationResolver) RevokePersonalAccessToken(ctx context.Context, id int) (bool, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	err := r.Models.PersonalAccessTokens.Revoke(userID, int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, gqlerror.Errorf("personal access token not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while revoking personal access token: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	return true, nil
+}
+
+// MyPersonalAccessTokens is the resolver for the myPersonalAccessTokens field.
+func (r *queryResolver) MyPersonalAccessTokens(ctx context.Context) ([]*model.PersonalAccessToken, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	tokens, err := r.Models.PersonalAccessTokens.GetAllForUser(userID)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting personal access tokens: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	result := make([]*model.PersonalAccessToken, len(tokens))
+	for i, token := range tokens {
+		result[i] = personalAccessToken(token)
+	}
+
+	return result, nil
+}
+
+// PersonalAccessTokenScopes is the resolver for the personalAccessTokenScopes field.
+func (r *queryResolver) PersonalAccessTokenScopes(ctx context.Context) ([]string, error) {
+	return data.TokenScopes, nil
+}
+
+func personalAccessToken(token *data.PersonalAccessToken) *model.PersonalAccessToken {
+	result := &model.PersonalAccessToken{
+		ID:        int(token.ID),
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt.Format(time.RFC3339),
+		ExpiresAt: token.Expiry.Format(time.RFC3339),
+	}
+
+	if token.LastUsedAt != nil {
+		lastUsedAt := token.LastUsedAt.Format(time.RFC3339)
+		result.LastUsedAt = &lastUsedAt
+	}
+
+	return result
+}
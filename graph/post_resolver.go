@@ -6,13 +6,15 @@ import (
 	"fmt"
 	"github.com/olzzhas/narxozer/graph/middleware"
 	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 // PostByID is the resolver for the postById field.
 func (r *queryResolver) PostByID(ctx context.Context, id int) (*model.Post, error) {
+	viewerID := middleware.GetUserIDFromContext(ctx)
 
-	post, err := r.Models.Posts.FindOne(int64(id))
+	post, err := r.Models.Posts.FindOne(int(viewerID), int64(id))
 	if err != nil {
 		r.Logger.PrintError(err, nil)
 		return nil, gqlerror.Errorf("internal server error")
@@ -33,20 +35,27 @@ func (r *queryResolver) PostByID(ctx context.Context, id int) (*model.Post, erro
 }
 
 // Posts is the resolver for the posts field.
-func (r *queryResolver) Posts(ctx context.Context) ([]*model.Post, error) {
-	posts, err := r.Models.Posts.FindAll()
+func (r *queryResolver) Posts(ctx context.Context, sort *model.RankingSort, first *int, after *string, last *int, before *string) (*model.PostConnection, error) {
+	ranking, filters, err := rankingFilters(sort, first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	viewerID := middleware.GetUserIDFromContext(ctx)
+
+	posts, err := r.Models.Posts.FindAll(int(viewerID), ranking, filters)
 	if err != nil {
 		r.Logger.PrintError(fmt.Errorf("error while getting posts: %v", err), nil)
 		return nil, gqlerror.Errorf("internal server error")
 	}
 
-	for _, post := range posts {
-		user, err := r.Models.Users.GetCached(post.Author.ID)
+	for _, edge := range posts.Edges {
+		user, err := r.Models.Users.GetCached(edge.Node.Author.ID)
 		if err != nil {
 			r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
 			return nil, gqlerror.Errorf("internal server error")
 		}
-		post.Author = user
+		edge.Node.Author = user
 	}
 
 	return posts, nil
@@ -55,15 +64,26 @@ func (r *queryResolver) Posts(ctx context.Context) ([]*model.Post, error) {
 // CreatePost is the resolver for the createPost field.
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePostInput) (*model.Post, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	if input.ClubID != nil && !r.Models.Clubs.IsAdmin(*input.ClubID, int(userID)) {
+		return nil, gqlerror.Errorf("only club admins can publish club announcements")
+	}
+
+	imageURL, err := r.contentImageURL(ctx, userID, data.UploadKindPost, input.Image, input.ImageURL)
+	if err != nil {
+		return nil, err
 	}
 
 	temp := model.Post{
 		Title:    input.Title,
 		Content:  input.Content,
-		ImageURL: input.ImageURL,
+		ImageURL: imageURL,
 		Author:   &model.User{ID: int(userID)},
+		ClubID:   input.ClubID,
 	}
 
 	post, err := r.Models.Posts.Insert(&temp)
@@ -86,12 +106,9 @@ func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePos
 // UpdatePost is the resolver for the updatePost field.
 func (r *mutationResolver) UpdatePost(ctx context.Context, id int, input model.UpdatePostInput) (*model.Post, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
-	}
 
 	// Получаем пост, чтобы обновить его поля
-	post, err := r.Models.Posts.FindOne(int64(id))
+	post, err := r.Models.Posts.FindOne(int(userID), int64(id))
 	if err != nil {
 		r.Logger.PrintError(err, nil)
 		return nil, gqlerror.Errorf("internal server error")
@@ -101,6 +118,10 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, id int, input model.U
 		return nil, gqlerror.Errorf("post not found")
 	}
 
+	if post.Author.ID != int(userID) {
+		return nil, gqlerror.Errorf("you have no permission to update this post")
+	}
+
 	if input.Title != nil {
 		post.Title = *input.Title
 	}
@@ -108,7 +129,10 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, id int, input model.U
 		post.Content = *input.Content
 	}
 	if input.ImageURL != nil {
-		post.ImageURL = input.ImageURL
+		post.ImageURL, err = r.attachedImageURL(userID, data.UploadKindPost, input.ImageURL)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	err = r.Models.Posts.Update(post)
@@ -131,12 +155,10 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, id int, input model.U
 // DeletePost is the resolver for the deletePost field.
 func (r *mutationResolver) DeletePost(ctx context.Context, id int) (bool, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return false, errors.New("unauthorized")
-	}
 
 	// TODO redis
-	post, err := r.Models.Posts.FindOne(int64(id))
+	// Блокировка не мешает модератору удалить пост, поэтому зритель анонимный
+	post, err := r.Models.Posts.FindOne(0, int64(id))
 	if err != nil {
 		return false, gqlerror.Errorf("internal server error")
 	}
@@ -145,14 +167,19 @@ func (r *mutationResolver) DeletePost(ctx context.Context, id int) (bool, error)
 		return false, gqlerror.Errorf("post not found")
 	}
 
-	user, err := r.Models.Users.GetCached(int(userID))
-	if err != nil {
-		r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
-		return false, gqlerror.Errorf("internal server error")
-	}
+	if post.Author.ID != int(userID) {
+		canModerate, err := r.hasPermission(ctx, data.PermissionPostsModerate)
+		if err != nil {
+			return false, err
+		}
 
-	if post.Author.ID != int(userID) && user.Role != model.RoleAdmin {
-		return false, gqlerror.Errorf("you have no permission to delete this post")
+		if !canModerate {
+			return false, gqlerror.Errorf("you have no permission to delete this post")
+		}
+
+		if err := r.requireMFA(ctx); err != nil {
+			return false, err
+		}
 	}
 
 	err = r.Models.Posts.Delete(int64(id))
@@ -167,61 +194,42 @@ func (r *mutationResolver) DeletePost(ctx context.Context, id int) (bool, error)
 // LikePost is the resolver for the likePost field.
 func (r *mutationResolver) LikePost(ctx context.Context, id int) (*model.Post, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, fmt.Errorf("unauthorized")
-	}
 
-	// Проверяем, не лайкнул ли уже этот пользователь данный пост
-	var existingLike int
-	err := r.Models.Posts.DB.QueryRow("SELECT COUNT(*) FROM likes WHERE user_id = $1 AND entity_id = $2 AND entity_type = 'post'", userID, id).Scan(&existingLike)
+	err := r.Models.Posts.ToggleLike(int(userID), int64(id))
 	if err != nil {
-		return nil, err
-	}
-
-	if existingLike > 0 {
-		// Если пользователь уже лайкнул пост, удаляем лайк (unlike)
-		_, err := r.Models.Posts.DB.Exec("DELETE FROM likes WHERE user_id = $1 AND entity_id = $2 AND entity_type = 'post'", userID, id)
-		if err != nil {
-			return nil, gqlerror.Errorf("internal server error")
-		}
-
-		// Уменьшаем счетчик лайков в таблице posts
-		_, err = r.Models.Posts.DB.Exec("UPDATE posts SET likes = likes - 1 WHERE id = $1", id)
-		if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, gqlerror.Errorf("post not found")
+		case errors.Is(err, data.ErrBlocked):
+			return nil, blockedError()
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while liking post: %v", err), nil)
 			return nil, gqlerror.Errorf("internal server error")
 		}
-
-		// Возвращаем обновленный пост
-		post := &model.Post{}
-		err = r.Models.Posts.DB.QueryRow("SELECT id, title, content, image_url, created_at, updated_at, likes FROM posts WHERE id = $1", id).Scan(
-			&post.ID, &post.Title, &post.Content, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &post.Likes)
-		if err != nil {
-			return nil, gqlerror.Errorf("internal server error")
-		}
-
-		return post, nil
 	}
 
-	// Добавляем лайк в таблицу likes
-	_, err = r.Models.Posts.DB.Exec("INSERT INTO likes (user_id, entity_id, entity_type) VALUES ($1, $2, 'post')", userID, id)
+	// Возвращаем обновленный пост
+	post, err := r.Models.Posts.FindOne(int(userID), int64(id))
 	if err != nil {
-		return nil, err
+		r.Logger.PrintError(err, nil)
+		return nil, gqlerror.Errorf("internal server error")
 	}
 
-	// Увеличиваем счетчик лайков в таблице posts
-	_, err = r.Models.Posts.DB.Exec("UPDATE posts SET likes = likes + 1 WHERE id = $1", id)
-	if err != nil {
-		return nil, err
+	if post == nil {
+		return nil, gqlerror.Errorf("post not found")
 	}
 
-	// Возвращаем обновленный пост
-	post := &model.Post{}
-	err = r.Models.Posts.DB.QueryRow("SELECT id, title, content, image_url, created_at, updated_at, likes FROM posts WHERE id = $1", id).Scan(
-		&post.ID, &post.Title, &post.Content, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &post.Likes)
+	user, err := r.Models.Users.GetCached(post.Author.ID)
 	if err != nil {
-		return nil, err
+		r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
 	}
+	post.Author = user
 
 	return post, nil
+}
 
+// ImageVariants is the resolver for the imageVariants field.
+func (r *postResolver) ImageVariants(ctx context.Context, obj *model.Post) (*model.ImageVariants, error) {
+	return r.imageVariants(obj.ImageURL), nil
 }
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// UpdatePrivacySettings is the resolver for the updatePrivacySettings field.
+func (r *mutationResolver) UpdatePrivacySettings(ctx context.Context, input model.UpdatePrivacySettingsInput) (*model.PrivacySettings, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	privacy, err := r.Models.Users.UpdatePrivacy(int(userID), input)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while updating privacy settings: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return privacy, nil
+}
+
+// canSeeProfileField сообщает, открыто ли поле профиля owner с настройкой
+// visibility текущему зрителю. Владелец и модераторы пользователей видят все
+// поля. Пустая настройка (профиль загружен не целиком или из старого кеша)
+// считается закрытой.
+func (r *Resolver) canSeeProfileField(ctx context.Context, owner *model.User, visibility model.ProfileVisibility) (bool, error) {
+	viewerID := int(middleware.GetUserIDFromContext(ctx))
+
+	if owner.IsViewer || owner.ID == viewerID || visibility == model.ProfileVisibilityEveryone {
+		return true, nil
+	}
+
+	if viewerID == 0 {
+		return false, nil
+	}
+
+	moderator, err := r.hasPermission(ctx, "users:manage")
+	if err != nil {
+		return false, err
+	}
+
+	if moderator {
+		return true, nil
+	}
+
+	switch visibility {
+	case model.ProfileVisibilityUniversity:
+		viewer, err := r.Models.Users.GetCached(viewerID)
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+		return viewer != nil && viewer.Activated, nil
+
+	case model.ProfileVisibilityFollowers:
+		status, err := r.Models.Follows.Status(viewerID, owner.ID)
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while getting follow status: %v", err), nil)
+			return false, gqlerror.Errorf("internal server error")
+		}
+		return status == data.FollowStatusAccepted, nil
+
+	default:
+		return false, nil
+	}
+}
@@ -4,19 +4,20 @@ import (
 	"github.com/olzzhas/narxozer/auth"
 	"github.com/olzzhas/narxozer/internal/data"
 	"github.com/olzzhas/narxozer/internal/jsonlog"
-	"time"
 )
 
 type Resolver struct {
-	Models     data.Models
-	Logger     *jsonlog.Logger
-	JWTManager auth.JWTManager
+	Models   data.Models
+	Storages data.Storages
+	Logger   *jsonlog.Logger
+	Auth     *auth.Service
 }
 
-func NewResolver(models data.Models, logger *jsonlog.Logger) *Resolver {
+func NewResolver(models data.Models, storages data.Storages, logger *jsonlog.Logger, authService *auth.Service) *Resolver {
 	return &Resolver{
-		Models:     models,
-		Logger:     logger,
-		JWTManager: *auth.NewJWTManager("your-secret-key", 24*time.Hour),
+		Models:   models,
+		Storages: storages,
+		Logger:   logger,
+		Auth:     authService,
 	}
 }
@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"strings"
+)
+
+// Search is the resolver for the search field.
+func (r *queryResolver) Search(ctx context.Context, query string, types []model.SearchType, first *int, after *string) (*model.SearchConnection, error) {
+	filters, err := cursorFilters(first, after, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validator.New()
+	if data.ValidateSearchQuery(v, query); !v.Valid() {
+		return nil, failedValidationError(v)
+	}
+
+	searchTypes := make([]string, 0, len(types))
+	for _, t := range types {
+		searchTypes = append(searchTypes, strings.ToLower(t.String()))
+	}
+
+	viewerID := middleware.GetUserIDFromContext(ctx)
+
+	hits, pageInfo, totalCount, err := r.Models.Search.Search(int(viewerID), query, searchTypes, filters)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while searching: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	edges := make([]*model.SearchEdge, 0, len(hits))
+	for _, hit := range hits {
+		node, err := r.searchResultNode(int(viewerID), hit)
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while loading search result: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+
+		// Запись могли удалить между поиском и загрузкой
+		if node == nil {
+			continue
+		}
+
+		edges = append(edges, &model.SearchEdge{
+			Cursor:  hit.Cursor,
+			Node:    node,
+			Rank:    hit.Rank,
+			Snippet: hit.Snippet,
+		})
+	}
+
+	return &model.SearchConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
+}
+
+func (r *queryResolver) searchResultNode(viewerID int, hit *data.SearchHit) (model.SearchResult, error) {
+	switch hit.Type {
+	case data.SearchTypePost:
+		post, err := r.Models.Posts.FindOne(viewerID, int64(hit.ID))
+		if err != nil || post == nil {
+			return nil, err
+		}
+
+		post.Author, err = r.Models.Users.GetCached(post.Author.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return post, nil
+	case data.SearchTypeTopic:
+		topic, err := r.Models.Topics.GetByID(viewerID, hit.ID)
+		if err != nil || topic == nil {
+			return nil, err
+		}
+
+		topic.Author, err = r.Models.Users.GetCached(topic.Author.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return topic, nil
+	case data.SearchTypeClub:
+		club, err := r.Models.Clubs.GetByID(hit.ID)
+		if err != nil || club == nil {
+			return nil, err
+		}
+
+		return club, nil
+	case data.SearchTypeEvent:
+		event, err := r.Models.Events.GetByID(hit.ID)
+		if err != nil || event == nil {
+			return nil, err
+		}
+
+		return event, nil
+	case data.SearchTypeUser:
+		user, err := r.Models.Users.GetCached(hit.ID)
+		if err != nil || user == nil {
+			return nil, err
+		}
+
+		return user, nil
+	}
+
+	return nil, fmt.Errorf("unknown search result type %q", hit.Type)
+}
@@ -6,24 +6,31 @@ import (
 	"fmt"
 	"github.com/olzzhas/narxozer/graph/middleware"
 	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 // CreateTopic is the resolver for the createTopic field.
 func (r *mutationResolver) CreateTopic(ctx context.Context, input model.CreateTopicInput) (*model.Topic, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	imageURL, err := r.contentImageURL(ctx, userID, data.UploadKindTopic, input.Image, input.ImageURL)
+	if err != nil {
+		return nil, err
 	}
 
 	topic := &model.Topic{
 		Title:    input.Title,
 		Content:  input.Content,
-		ImageURL: input.ImageURL,
+		ImageURL: imageURL,
 		Author:   &model.User{ID: int(userID)},
 	}
 
-	topic, err := r.Models.Topics.Insert(topic)
+	topic, err = r.Models.Topics.Insert(topic)
 	if err != nil {
 		return nil, err
 	}
@@ -42,11 +49,8 @@ func (r *mutationResolver) CreateTopic(ctx context.Context, input model.CreateTo
 // UpdateTopic is the resolver for the updateTopic field.
 func (r *mutationResolver) UpdateTopic(ctx context.Context, id int, input model.UpdateTopicInput) (*model.Topic, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
-	}
 
-	topic, err := r.Models.Topics.GetByID(id)
+	topic, err := r.Models.Topics.GetByID(int(userID), id)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +64,10 @@ func (r *mutationResolver) UpdateTopic(ctx context.Context, id int, input model.
 
 	topic.Title = *input.Title
 	topic.Content = *input.Content
-	topic.ImageURL = input.ImageURL
+	topic.ImageURL, err = r.attachedImageURL(userID, data.UploadKindTopic, input.ImageURL)
+	if err != nil {
+		return nil, err
+	}
 
 	updatedTopic, err := r.Models.Topics.Update(topic)
 	if err != nil {
@@ -81,11 +88,8 @@ func (r *mutationResolver) UpdateTopic(ctx context.Context, id int, input model.
 // DeleteTopic is the resolver for the deleteTopic field.
 func (r *mutationResolver) DeleteTopic(ctx context.Context, id int) (bool, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return false, errors.New("unauthorized")
-	}
 
-	topic, err := r.Models.Topics.GetByID(id)
+	topic, err := r.Models.Topics.GetByID(int(userID), id)
 	if err != nil {
 		return false, err
 	}
@@ -109,63 +113,38 @@ func (r *mutationResolver) DeleteTopic(ctx context.Context, id int) (bool, error
 // LikeTopic is the resolver for the likeTopic field.
 func (r *mutationResolver) LikeTopic(ctx context.Context, id int) (*model.Topic, error) {
 	userID := middleware.GetUserIDFromContext(ctx)
-	if userID == 0 {
-		return nil, errors.New("unauthorized")
-	}
 
-	// Проверяем, лайкнул ли уже этот пользователь данный топик
-	var existingLike int
-	err := r.Models.Posts.DB.QueryRow("SELECT COUNT(*) FROM likes WHERE user_id = $1 AND entity_id = $2 AND entity_type = 'topic'", userID, id).Scan(&existingLike)
+	topic, err := r.Models.Topics.GetByID(int(userID), id)
 	if err != nil {
 		return nil, err
 	}
-
-	tx, err := r.Models.Posts.DB.Begin() // Начало транзакции
-	if err != nil {
-		return nil, err
+	if topic == nil {
+		return nil, errors.New("topic not found")
 	}
 
-	if existingLike > 0 {
-		// Лайк уже существует, выполняем анлайк
-		_, err = tx.Exec("DELETE FROM likes WHERE user_id = $1 AND entity_id = $2 AND entity_type = 'topic'", userID, id)
-		if err != nil {
-			tx.Rollback()
-			return nil, err
-		}
-
-		// Уменьшаем счетчик лайков в таблице topics, убедившись, что он не станет отрицательным
-		_, err = tx.Exec("UPDATE topics SET likes = GREATEST(likes - 1, 0) WHERE id = $1", id)
-		if err != nil {
-			tx.Rollback()
-			return nil, err
-		}
-	} else {
-		// Лайка нет, выполняем добавление лайка
-		_, err = tx.Exec("INSERT INTO likes (user_id, entity_id, entity_type) VALUES ($1, $2, 'topic')", userID, id)
-		if err != nil {
-			tx.Rollback()
-			return nil, err
-		}
-
-		// Увеличиваем счетчик лайков в таблице topics
-		_, err = tx.Exec("UPDATE topics SET likes = likes + 1 WHERE id = $1", id)
-		if err != nil {
-			tx.Rollback()
+	// Блокировку, появившуюся после проверки выше, отсекает ToggleLike
+	err = r.Models.Topics.ToggleLike(int(userID), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, errors.New("topic not found")
+		case errors.Is(err, data.ErrBlocked):
+			return nil, blockedError()
+		default:
 			return nil, err
 		}
 	}
 
-	if err = tx.Commit(); err != nil { // Завершение транзакции
-		return nil, err
-	}
-
 	// Возвращаем обновленный топик
-	topic, err := r.Models.Topics.GetByID(id)
+	topic, err = r.Models.Topics.GetByID(int(userID), id)
 	if err != nil {
 		return nil, err
 	}
+	if topic == nil {
+		return nil, errors.New("topic not found")
+	}
 
-	user, err := r.Models.Users.GetCached(int(userID))
+	user, err := r.Models.Users.GetCached(topic.Author.ID)
 	if err != nil {
 		r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
 		return nil, gqlerror.Errorf("internal server error")
@@ -177,19 +156,26 @@ func (r *mutationResolver) LikeTopic(ctx context.Context, id int) (*model.Topic,
 }
 
 // Topics is the resolver for the topics field.
-func (r *queryResolver) Topics(ctx context.Context) ([]*model.Topic, error) {
-	topics, err := r.Models.Topics.GetAll()
+func (r *queryResolver) Topics(ctx context.Context, sort *model.RankingSort, first *int, after *string, last *int, before *string) (*model.TopicConnection, error) {
+	ranking, filters, err := rankingFilters(sort, first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	viewerID := middleware.GetUserIDFromContext(ctx)
+
+	topics, err := r.Models.Topics.GetAll(int(viewerID), ranking, filters)
 	if err != nil {
 		return nil, err
 	}
 
 	// Загружаем авторов для каждого топика
-	for _, topic := range topics {
-		user, err := r.Models.Users.Get(topic.Author.ID)
+	for _, edge := range topics.Edges {
+		user, err := r.Models.Users.GetCached(edge.Node.Author.ID)
 		if err != nil {
 			return nil, err
 		}
-		topic.Author = user
+		edge.Node.Author = user
 	}
 
 	return topics, nil
@@ -197,7 +183,9 @@ func (r *queryResolver) Topics(ctx context.Context) ([]*model.Topic, error) {
 
 // TopicByID is the resolver for the topicById field.
 func (r *queryResolver) TopicByID(ctx context.Context, id int) (*model.Topic, error) {
-	topic, err := r.Models.Topics.GetByID(id)
+	viewerID := middleware.GetUserIDFromContext(ctx)
+
+	topic, err := r.Models.Topics.GetByID(int(viewerID), id)
 	if err != nil {
 		return nil, err
 	}
@@ -216,20 +204,32 @@ func (r *queryResolver) TopicByID(ctx context.Context, id int) (*model.Topic, er
 }
 
 // CommentsByTopicID is the resolver for the commentsByTopicId field.
-func (r *queryResolver) CommentsByTopicID(ctx context.Context, topicID int) ([]*model.Comment, error) {
-	comments, err := r.Models.Comments.GetByEntityID(topicID, "topic")
+func (r *queryResolver) CommentsByTopicID(ctx context.Context, topicID int, first *int, after *string, last *int, before *string) (*model.CommentConnection, error) {
+	filters, err := cursorFilters(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	viewerID := middleware.GetUserIDFromContext(ctx)
+
+	comments, err := r.Models.Comments.GetByEntityID(int(viewerID), topicID, model.EntityTypeTopic.String(), filters)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, comment := range comments {
-		user, err := r.Models.Users.GetCached(comment.Author.ID)
+	for _, edge := range comments.Edges {
+		user, err := r.Models.Users.GetCached(edge.Node.Author.ID)
 		if err != nil {
 			r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
 			return nil, gqlerror.Errorf("internal server error")
 		}
-		comment.Author = user
+		edge.Node.Author = user
 	}
 
 	return comments, nil
 }
+
+// ImageVariants is the resolver for the imageVariants field.
+func (r *topicResolver) ImageVariants(ctx context.Context, obj *model.Topic) (*model.ImageVariants, error) {
+	return r.imageVariants(obj.ImageURL), nil
+}
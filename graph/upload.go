@@ -0,0 +1,156 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/image"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"io"
+)
+
+// Каталоги в хранилище для изображений каждого назначения
+var uploadFolders = map[string]string{
+	data.UploadKindPost:    "posts",
+	data.UploadKindTopic:   "topics",
+	data.UploadKindClub:    "clubs",
+	data.UploadKindEvent:   "events",
+	data.UploadKindComment: "comments",
+	data.UploadKindAvatar:  "users",
+}
+
+// contentImageURL возвращает ссылку на изображение контента: сохраняет файл
+// из multipart-запроса или прикрепляет загрузку, id которой передан в
+// imageURL
+func (r *Resolver) contentImageURL(ctx context.Context, userID int64, kind string, upload *graphql.Upload, imageURL *string) (*string, error) {
+	if upload == nil {
+		return r.attachedImageURL(userID, kind, imageURL)
+	}
+
+	v := validator.New()
+	v.Check(imageURL == nil, "imageURL", "must not be combined with image")
+	if !v.Valid() {
+		return nil, failedValidationError(v)
+	}
+
+	key, err := r.storeImage(ctx, userID, kind, upload.File)
+	if err != nil {
+		return nil, err
+	}
+
+	url := r.Storages.ImageURL(key)
+
+	return &url, nil
+}
+
+// attachedImageURL проверяет, что uploadID — подтвержденная загрузка
+// пользователя с назначением kind, и возвращает ссылку на ее изображение.
+// Произвольные URL не принимаются.
+func (r *Resolver) attachedImageURL(userID int64, kind string, uploadID *string) (*string, error) {
+	if uploadID == nil {
+		return nil, nil
+	}
+
+	key, err := r.Models.Uploads.Attach(*uploadID, userID, kind)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v := validator.New()
+			v.AddError("imageURL", "must be the id of a confirmed upload")
+			return nil, failedValidationError(v)
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while attaching upload: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	url := r.Storages.ImageURL(key)
+
+	return &url, nil
+}
+
+// storeImage перекодирует изображение во все варианты, записывает их в
+// хранилище под случайным именем в каталоге назначения kind и возвращает
+// ключ варианта large
+func (r *Resolver) storeImage(ctx context.Context, userID int64, kind string, file io.Reader) (string, error) {
+	variants, err := image.Process(file)
+	if err != nil {
+		v := validator.New()
+
+		switch {
+		case errors.Is(err, image.ErrUnsupportedFormat):
+			v.AddError("image", "must be a JPEG, PNG or WebP image")
+		case errors.Is(err, image.ErrFileTooLarge):
+			v.AddError("image", fmt.Sprintf("must not be larger than %d MB", image.MaxFileSize>>20))
+		case errors.Is(err, image.ErrTooManyPixels):
+			v.AddError("image", fmt.Sprintf("must not be larger than %dx%d pixels", image.MaxDimension, image.MaxDimension))
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while processing image: %v", err), nil)
+			return "", gqlerror.Errorf("internal server error")
+		}
+
+		return "", failedValidationError(v)
+	}
+
+	name := make([]byte, 16)
+	_, err = rand.Read(name)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while generating image name: %v", err), nil)
+		return "", gqlerror.Errorf("internal server error")
+	}
+
+	base := fmt.Sprintf("%s/%d/%s", uploadFolders[kind], userID, hex.EncodeToString(name))
+
+	var largeKey string
+	for _, variant := range variants {
+		key := base + "_" + variant.Name + variant.Extension
+
+		err = r.Storages.Images.Put(ctx, key, variant.ContentType, bytes.NewReader(variant.Data), int64(len(variant.Data)))
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while storing image: %v", err), nil)
+			return "", gqlerror.Errorf("internal server error")
+		}
+
+		if variant.Name == image.VariantLarge {
+			largeKey = key
+		}
+	}
+
+	return largeKey, nil
+}
+
+// imageVariants строит ссылки на варианты изображения по его imageURL.
+// Изображения не из нашего хранилища вариантов не имеют, поэтому все три
+// ссылки ведут на исходный файл.
+func (r *Resolver) imageVariants(imageURL *string) *model.ImageVariants {
+	if imageURL == nil || *imageURL == "" {
+		return nil
+	}
+
+	url := *imageURL
+	variants := &model.ImageVariants{Small: url, Medium: url, Large: url}
+
+	key, ok := r.Storages.ImageKey(url)
+	if !ok {
+		return variants
+	}
+
+	for name, dst := range map[string]*string{
+		image.VariantSmall:  &variants.Small,
+		image.VariantMedium: &variants.Medium,
+		image.VariantLarge:  &variants.Large,
+	} {
+		if variantKey, ok := image.VariantKey(key, name); ok {
+			*dst = r.Storages.ImageURL(variantKey)
+		}
+	}
+
+	return variants
+}
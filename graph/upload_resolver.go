@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/middleware"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/image"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"strings"
+	"time"
+)
+
+// uploadURLTTL — срок действия ссылки для загрузки файла
+const uploadURLTTL = 15 * time.Minute
+
+// RequestUpload is the resolver for the requestUpload field.
+func (r *mutationResolver) RequestUpload(ctx context.Context, kind model.UploadKind, contentType string, size int) (*model.UploadTicket, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	if err := r.requireActivatedUser(userID); err != nil {
+		return nil, err
+	}
+
+	v := validator.New()
+	if data.ValidateUpload(v, contentType, int64(size)); !v.Valid() {
+		return nil, failedValidationError(v)
+	}
+
+	upload, err := r.Models.Uploads.New(userID, strings.ToLower(kind.String()), contentType, int64(size))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while creating upload: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	url, err := r.Storages.Images.SignedPutURL(ctx, upload.ObjectKey(), contentType, uploadURLTTL)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while signing upload url: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return &model.UploadTicket{
+		ID:        upload.ID,
+		URL:       url,
+		ExpiresAt: upload.CreatedAt.Add(uploadURLTTL).Format(time.RFC3339),
+	}, nil
+}
+
+// ConfirmUpload is the resolver for the confirmUpload field.
+func (r *mutationResolver) ConfirmUpload(ctx context.Context, id string) (*model.ConfirmedUpload, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+
+	upload, err := r.Models.Uploads.GetPending(id, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, gqlerror.Errorf("upload not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while getting upload: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	objectKey := upload.ObjectKey()
+
+	size, err := r.Storages.Images.Size(ctx, objectKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, image.ErrObjectNotFound):
+			return nil, gqlerror.Errorf("file has not been uploaded yet")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while checking uploaded file: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	// Подписанная ссылка S3 не ограничивает размер, поэтому сверяем его здесь
+	v := validator.New()
+	v.Check(size <= upload.Size, "size", "uploaded file is larger than declared")
+	if !v.Valid() {
+		r.deleteUploadedFile(ctx, objectKey)
+		return nil, failedValidationError(v)
+	}
+
+	file, err := r.Storages.Images.Open(ctx, objectKey)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while opening uploaded file: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+	defer file.Close()
+
+	// Файл проходит ту же обработку, что и multipart-загрузка: проверку
+	// содержимого и перекодирование без EXIF
+	key, err := r.storeImage(ctx, userID, upload.Kind, file)
+	if err != nil {
+		// Отклоненный файл не должен оставаться в хранилище
+		r.deleteUploadedFile(ctx, objectKey)
+		return nil, err
+	}
+
+	err = r.Models.Uploads.Confirm(upload.ID, userID, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, gqlerror.Errorf("upload not found")
+		default:
+			r.Logger.PrintError(fmt.Errorf("error while confirming upload: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+	}
+
+	// Исходный файл с метаданными больше не нужен
+	r.deleteUploadedFile(ctx, objectKey)
+
+	imageURL := r.Storages.ImageURL(key)
+
+	return &model.ConfirmedUpload{
+		ID:            upload.ID,
+		Kind:          model.UploadKind(strings.ToUpper(upload.Kind)),
+		ImageURL:      imageURL,
+		ImageVariants: r.imageVariants(&imageURL),
+	}, nil
+}
+
+// deleteUploadedFile удаляет исходный файл прямой загрузки
+func (r *mutationResolver) deleteUploadedFile(ctx context.Context, objectKey string) {
+	err := r.Storages.Images.Delete(ctx, objectKey)
+	if err != nil && !errors.Is(err, image.ErrObjectNotFound) {
+		r.Logger.PrintError(fmt.Errorf("error while deleting uploaded file: %v", err), nil)
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/go-redis/redis/v8"
 	"github.com/olzzhas/narxozer/graph/middleware"
 	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/data"
+	"github.com/olzzhas/narxozer/internal/validator"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 	"time"
 )
@@ -15,10 +18,28 @@ import (
 // UpdateUser is the resolver for the updateUser field.
 func (r *mutationResolver) UpdateUser(ctx context.Context, id int, input model.UpdateUserInput) (*model.User, error) {
 	userId := middleware.GetUserIDFromContext(ctx)
-	if userId == 0 || id != int(userId) {
+	if id != int(userId) {
 		return nil, gqlerror.Errorf("you have no permission to update this user")
 	}
 
+	if input.ImageURL != nil {
+		imageURL, err := r.attachedImageURL(userId, data.UploadKindAvatar, input.ImageURL)
+		if err != nil {
+			return nil, err
+		}
+		input.ImageURL = imageURL
+	}
+
+	// Открытый аккаунт не держит заявок: одобряем их до обновления, чтобы
+	// сохраненный в кеше профиль содержал актуальные счетчики
+	if input.RequiresFollowApproval != nil && !*input.RequiresFollowApproval {
+		err := r.Models.Follows.AcceptAllPending(id)
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while accepting follow requests: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+	}
+
 	// Обновляем данные пользователя в базе данных
 	user, err := r.Models.Users.Update(id, input)
 	if err != nil {
@@ -26,6 +47,15 @@ func (r *mutationResolver) UpdateUser(ctx context.Context, id int, input model.U
 		return nil, gqlerror.Errorf("internal server error")
 	}
 
+	// Права по умолчанию зависят от роли
+	if input.Role != nil {
+		err = r.Models.Permissions.Invalidate(int64(id))
+		if err != nil {
+			r.Logger.PrintError(fmt.Errorf("error while invalidating permissions: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+	}
+
 	// Обновляем данные в кеше Redis
 	cacheKey := fmt.Sprintf("user:%d", id)
 	data, err := json.Marshal(user)
@@ -43,46 +73,82 @@ func (r *mutationResolver) UpdateUser(ctx context.Context, id int, input model.U
 	return user, nil
 }
 
-// Users is the resolver for the users field.
-func (r *queryResolver) Users(ctx context.Context) ([]*model.User, error) {
-	cacheKey := "users:all"
+// UpdateProfileImage is the resolver for the updateProfileImage field.
+func (r *mutationResolver) UpdateProfileImage(ctx context.Context, image graphql.Upload) (*model.User, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
 
-	// Пытаемся получить данные из кеша Redis
-	val, err := r.Models.Users.Redis.Get(ctx, cacheKey).Result()
-	if errors.Is(err, redis.Nil) {
-		// Данные не найдены в кеше, загружаем из базы данных
-		users, err := r.Models.Users.GetAll()
-		if err != nil {
-			return nil, err
-		}
+	key, err := r.storeImage(ctx, userID, data.UploadKindAvatar, image.File)
+	if err != nil {
+		return nil, err
+	}
 
-		// Сохраняем данные в кеш Redis
-		data, err := json.Marshal(users)
-		if err != nil {
-			return nil, err
-		}
-		err = r.Models.Users.Redis.Set(ctx, cacheKey, data, 10*time.Minute).Err()
-		if err != nil {
-			return nil, err
-		}
+	imageURL := r.Storages.ImageURL(key)
 
-		return users, nil
-	} else if err != nil {
-		return nil, err
+	user, err := r.Models.Users.Update(int(userID), model.UpdateUserInput{ImageURL: &imageURL})
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while updating user: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
 	}
 
-	// Если данные найдены в кеше, десериализуем их и возвращаем
-	var users []*model.User
-	err = json.Unmarshal([]byte(val), &users)
+	err = r.Models.Users.Redis.Del(ctx, fmt.Sprintf("user:%d", userID)).Err()
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while updating cache: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	return user, nil
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, filter *model.UserFilter, sort *string, first *int, after *string, last *int, before *string) (*model.UserConnection, error) {
+	page, err := cursorFilters(first, after, last, before)
 	if err != nil {
 		return nil, err
 	}
 
+	filters := data.Filters{
+		Sort:         "id",
+		SortSafelist: data.UserSortSafelist,
+	}
+	if sort != nil {
+		filters.Sort = *sort
+	}
+
+	v := validator.New()
+	if data.ValidateSort(v, filters); !v.Valid() {
+		return nil, failedValidationError(v)
+	}
+
+	if filter == nil {
+		filter = &model.UserFilter{}
+	}
+
+	viewerID := middleware.GetUserIDFromContext(ctx)
+
+	users, err := r.Models.Users.GetAll(int(viewerID), *filter, filters, page)
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while getting users: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
 	return users, nil
 }
 
 // UserByID is the resolver for the userById field.
 func (r *queryResolver) UserByID(ctx context.Context, id int) (*model.User, error) {
+	viewerID := middleware.GetUserIDFromContext(ctx)
+
+	// Для заблокированного профиль выглядит так, будто его нет
+	blocked, err := r.Models.Blocks.IsBlocked(id, int(viewerID))
+	if err != nil {
+		r.Logger.PrintError(fmt.Errorf("error while checking block: %v", err), nil)
+		return nil, gqlerror.Errorf("internal server error")
+	}
+
+	if blocked {
+		return nil, nil
+	}
+
 	cacheKey := fmt.Sprintf("user:%d", id)
 
 	// Пытаемся получить данные из кеша Redis
@@ -118,3 +184,77 @@ func (r *queryResolver) UserByID(ctx context.Context, id int) (*model.User, erro
 
 	return user, nil
 }
+
+// ImageVariants is the resolver for the imageVariants field.
+func (r *userResolver) ImageVariants(ctx context.Context, obj *model.User) (*model.ImageVariants, error) {
+	return r.imageVariants(obj.ImageURL), nil
+}
+
+// Email is the resolver for the email field.
+func (r *userResolver) Email(ctx context.Context, obj *model.User) (*string, error) {
+	visible, err := r.canSeeProfileField(ctx, obj, obj.Privacy.Email)
+	if err != nil || !visible {
+		return nil, err
+	}
+
+	return &obj.Email, nil
+}
+
+// AdditionalInformation is the resolver for the additionalInformation field.
+func (r *userResolver) AdditionalInformation(ctx context.Context, obj *model.User) (*string, error) {
+	visible, err := r.canSeeProfileField(ctx, obj, obj.Privacy.AdditionalInformation)
+	if err != nil || !visible {
+		return nil, err
+	}
+
+	return obj.AdditionalInformation, nil
+}
+
+// Course is the resolver for the course field.
+func (r *userResolver) Course(ctx context.Context, obj *model.User) (*int, error) {
+	visible, err := r.canSeeProfileField(ctx, obj, obj.Privacy.Course)
+	if err != nil || !visible {
+		return nil, err
+	}
+
+	return obj.Course, nil
+}
+
+// Major is the resolver for the major field.
+func (r *userResolver) Major(ctx context.Context, obj *model.User) (*string, error) {
+	visible, err := r.canSeeProfileField(ctx, obj, obj.Privacy.Major)
+	if err != nil || !visible {
+		return nil, err
+	}
+
+	return obj.Major, nil
+}
+
+// Faculty is the resolver for the faculty field.
+func (r *userResolver) Faculty(ctx context.Context, obj *model.User) (*string, error) {
+	visible, err := r.canSeeProfileField(ctx, obj, obj.Privacy.Faculty)
+	if err != nil || !visible {
+		return nil, err
+	}
+
+	return obj.Faculty, nil
+}
+
+// PrivacySettings is the resolver for the privacySettings field.
+func (r *userResolver) PrivacySettings(ctx context.Context, obj *model.User) (*model.PrivacySettings, error) {
+	if !obj.IsViewer && obj.ID != int(middleware.GetUserIDFromContext(ctx)) {
+		return nil, nil
+	}
+
+	// Профиль мог быть загружен не целиком, например из списка участников клуба
+	if obj.Privacy.Email == "" {
+		user, err := r.Models.Users.Get(obj.ID)
+		if err != nil || user == nil {
+			r.Logger.PrintError(fmt.Errorf("error while getting user: %v", err), nil)
+			return nil, gqlerror.Errorf("internal server error")
+		}
+		return &user.Privacy, nil
+	}
+
+	return &obj.Privacy, nil
+}
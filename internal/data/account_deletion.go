@@ -0,0 +1,372 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// PurgedFiles — файлы удаленного аккаунта, которые нужно убрать из хранилища
+type PurgedFiles struct {
+	// ImageURLs — аватар и изображения удаленного контента
+	ImageURLs []string
+	// ImageKeys — обработанные загрузки, так и не прикрепленные к контенту
+	ImageKeys []string
+	// ObjectKeys — остальные файлы, например архивы с данными
+	ObjectKeys []string
+}
+
+// ScheduleDeletion планирует удаление аккаунта на момент at. До него
+// удаление можно отменить через CancelDeletion.
+func (m UserModel) ScheduleDeletion(id int, at time.Time) error {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, at)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// CancelDeletion отменяет запланированное удаление. Возвращает
+// ErrRecordNotFound, если удаление не запланировано.
+func (m UserModel) CancelDeletion(id int) error {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetDueForDeletion возвращает до limit аккаунтов, срок отмены удаления
+// которых истек
+func (m UserModel) GetDueForDeletion(limit int) ([]int, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE deletion_scheduled_at <= NOW() AND deleted_at IS NULL
+		ORDER BY deletion_scheduled_at
+		LIMIT $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ownContentCondition выбирает строки comments или likes, относящиеся к
+// личным постам и топикам пользователя $1
+const ownContentCondition = `(
+		(entity_type = 'post' AND entity_id IN (SELECT id FROM posts WHERE author_id = $1 AND club_id IS NULL))
+		OR (entity_type = 'topic' AND entity_id IN (SELECT id FROM topics WHERE author_id = $1))
+	)`
+
+// Purge удаляет аккаунт, срок отмены удаления которого истек:
+//   - профиль обезличивается, но строка остается: на нее ссылаются
+//     сохраненные комментарии и созданные пользователем клубы. Войти в
+//     аккаунт больше нельзя;
+//   - личные посты и топики удаляются вместе с комментариями и лайками к
+//     ним. Объявления остаются за клубами;
+//   - комментарии без ответов удаляются, а у комментариев с ответами
+//     стираются текст и изображение, чтобы не распадались чужие обсуждения;
+//   - лайки удаляются с уменьшением счетчиков;
+//   - подписки, блокировки, членство в клубах, права, сессии, токены,
+//     загрузки и архивы с данными удаляются, а в журнале безопасности
+//     email заменяется обезличенным.
+//
+// Возвращает ErrRecordNotFound, если удаление отменили.
+func (m UserModel) Purge(id int) (*PurgedFiles, error) {
+	// Удаление может затронуть много строк, поэтому таймаут больше обычного
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	files := &PurgedFiles{}
+
+	var avatar sql.NullString
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT image_url
+		FROM users
+		WHERE id = $1 AND deletion_scheduled_at <= NOW() AND deleted_at IS NULL
+		FOR UPDATE
+	`, id).Scan(&avatar)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if avatar.Valid {
+		files.ImageURLs = append(files.ImageURLs, avatar.String)
+	}
+
+	// Лайки пользователя
+	_, err = tx.ExecContext(ctx, `
+		WITH removed AS (
+			DELETE FROM likes WHERE user_id = $1
+			RETURNING entity_type, entity_id
+		), posts_updated AS (
+			UPDATE posts SET likes = GREATEST(likes - 1, 0)
+			WHERE id IN (SELECT entity_id FROM removed WHERE entity_type = 'post')
+		), topics_updated AS (
+			UPDATE topics SET likes = GREATEST(likes - 1, 0)
+			WHERE id IN (SELECT entity_id FROM removed WHERE entity_type = 'topic')
+		)
+		UPDATE comments SET likes = GREATEST(likes - 1, 0)
+		WHERE id IN (SELECT entity_id FROM removed WHERE entity_type = 'comment')
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Личные посты и топики вместе с обсуждениями
+	for _, query := range []string{
+		`DELETE FROM likes WHERE entity_type = 'comment' AND entity_id IN (SELECT id FROM comments WHERE ` + ownContentCondition + `)`,
+		`DELETE FROM likes WHERE ` + ownContentCondition,
+	} {
+		_, err = tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, query := range []string{
+		`DELETE FROM comments WHERE ` + ownContentCondition + ` RETURNING image_url`,
+		`DELETE FROM posts WHERE author_id = $1 AND club_id IS NULL RETURNING image_url`,
+		`DELETE FROM topics WHERE author_id = $1 RETURNING image_url`,
+	} {
+		urls, _, err := queryStrings(ctx, tx, query, id)
+		if err != nil {
+			return nil, err
+		}
+		files.ImageURLs = append(files.ImageURLs, urls...)
+	}
+
+	// Комментарии в чужих обсуждениях. Удаляем листья, пока они есть:
+	// после удаления ответов пользователя листом может стать и его
+	// собственный комментарий выше по ветке.
+	for {
+		urls, removed, err := queryStrings(ctx, tx, `
+			WITH removed AS (
+				DELETE FROM comments c
+				WHERE c.author_id = $1 AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+				RETURNING c.id, c.image_url
+			), likes_removed AS (
+				DELETE FROM likes WHERE entity_type = 'comment' AND entity_id IN (SELECT id FROM removed)
+			)
+			SELECT image_url FROM removed
+		`, id)
+		if err != nil {
+			return nil, err
+		}
+		files.ImageURLs = append(files.ImageURLs, urls...)
+
+		if removed == 0 {
+			break
+		}
+	}
+
+	urls, _, err := queryStrings(ctx, tx, `
+		SELECT image_url FROM comments WHERE author_id = $1 AND image_url IS NOT NULL
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	files.ImageURLs = append(files.ImageURLs, urls...)
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE comments
+		SET content = '', image_url = NULL, updated_at = NOW()
+		WHERE author_id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Подписки: триггер пересчитает счетчики, а профили и ленты
+	// собеседников нужно сбросить из кеша
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM follows
+		WHERE follower_id = $1 OR followee_id = $1
+		RETURNING CASE WHEN follower_id = $1 THEN followee_id ELSE follower_id END
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKeys := []string{fmt.Sprintf("user:%d", id), feedKey(id)}
+	for rows.Next() {
+		var otherID int
+		if err := rows.Scan(&otherID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		cacheKeys = append(cacheKeys, fmt.Sprintf("user:%d", otherID), feedKey(otherID))
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1`,
+		`DELETE FROM user_mutes WHERE muter_id = $1 OR muted_id = $1`,
+		`DELETE FROM club_members WHERE user_id = $1`,
+		`DELETE FROM club_admins WHERE user_id = $1`,
+		`DELETE FROM users_permissions WHERE user_id = $1`,
+		`DELETE FROM tokens WHERE user_id = $1`,
+		`DELETE FROM authorization_tokens WHERE user_id = $1`,
+		`DELETE FROM personal_access_tokens WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM users_totp WHERE user_id = $1`,
+		`DELETE FROM mfa_recovery_codes WHERE user_id = $1`,
+	} {
+		_, err = tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files.ImageKeys, _, err = queryStrings(ctx, tx, `
+		DELETE FROM uploads WHERE user_id = $1
+		RETURNING CASE WHEN attached_at IS NULL THEN image_key END
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	files.ObjectKeys, _, err = queryStrings(ctx, tx, `
+		DELETE FROM data_exports WHERE user_id = $1 RETURNING object_key
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Загрузку можно прикрепить несколько раз, поэтому изображение удаленного
+	// поста могло остаться у объявления, клуба или события
+	files.ImageURLs, _, err = queryStrings(ctx, tx, `
+		SELECT DISTINCT url FROM unnest($1::text[]) url
+		WHERE NOT EXISTS (SELECT 1 FROM posts WHERE image_url = url)
+		  AND NOT EXISTS (SELECT 1 FROM clubs WHERE image_url = url)
+		  AND NOT EXISTS (SELECT 1 FROM events WHERE image_url = url)
+	`, pq.Array(files.ImageURLs))
+	if err != nil {
+		return nil, err
+	}
+
+	anonymousEmail := fmt.Sprintf("deleted-%d@users.invalid", id)
+
+	_, err = tx.ExecContext(ctx, `UPDATE security_events SET email = $2 WHERE user_id = $1`, id, anonymousEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пустой хеш не совпадет ни с одним паролем
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET email = $2, name = 'Deleted', lastname = 'User', password_hash = '', image_url = NULL,
+		    additional_information = NULL, course = NULL, major = NULL, degree = NULL, faculty = NULL,
+		    activated = false, follow_approval_required = false,
+		    deletion_scheduled_at = NULL, deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, id, anonymousEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return files, m.Redis.Del(ctx, cacheKeys...).Err()
+}
+
+// queryStrings выполняет запрос с одной текстовой колонкой и возвращает ее
+// непустые значения и общее число строк
+func queryStrings(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, int, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var values []string
+	count := 0
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return nil, 0, err
+		}
+		count++
+		if value.Valid && value.String != "" {
+			values = append(values, value.String)
+		}
+	}
+
+	return values, count, rows.Err()
+}
@@ -1,72 +0,0 @@
-package data
-
-import (
-	"errors"
-	"github.com/dgrijalva/jwt-go"
-	"time"
-)
-
-type JWTManager struct {
-	secretKey     string
-	tokenDuration time.Duration
-}
-
-type UserClaims struct {
-	jwt.StandardClaims
-	UserID int64  `json:"user_id"`
-	Role   string `json:"role"`
-}
-
-func NewJWTManager(secretKey string, tokenDuration time.Duration) *JWTManager {
-	return &JWTManager{
-		secretKey:     secretKey,
-		tokenDuration: tokenDuration,
-	}
-}
-
-func (manager *JWTManager) Generate(userID int64, role string) (string, error) {
-	claims := &UserClaims{
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(manager.tokenDuration).Unix(),
-		},
-		UserID: userID,
-		Role:   role,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(manager.secretKey))
-}
-
-func (manager *JWTManager) GenerateRefresh(userID int64, role string) (string, error) {
-	claims := &UserClaims{
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(7 * 24 * time.Hour).Unix(),
-		},
-		UserID: userID,
-		Role:   role,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(manager.secretKey))
-}
-
-func (manager *JWTManager) Verify(tokenString string) (*UserClaims, error) {
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&UserClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(manager.secretKey), nil
-		},
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(*UserClaims)
-	if !ok {
-		return nil, errors.New("invalid token claims")
-	}
-
-	return claims, nil
-}
@@ -2,18 +2,37 @@ package data
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
-	"github.com/dgrijalva/jwt-go"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
-	"os"
+	"github.com/olzzhas/narxozer/graph/model"
 	"time"
 )
 
+// RefreshTokenTTL — время жизни одного refresh токена. Каждая ротация
+// выдает новый токен с полным сроком.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// ErrRefreshTokenReused возвращается, когда предъявлен уже обмененный
+// refresh токен. К этому моменту вся сессия уже отозвана.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
 type AuthorizationToken struct {
-	ID           int64  `json:"id"`
-	UserID       int64  `json:"user_id"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	SessionID string    `json:"session_id"`
+	Plaintext string    `json:"-"`
+	Hash      []byte    `json:"-"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+	Expiry    time.Time `json:"expiry"`
+	// MFA — сессия открыта с подтверждением вторым фактором
+	MFA bool `json:"mfa"`
 }
 
 type AuthorizationTokenModel struct {
@@ -21,115 +40,302 @@ type AuthorizationTokenModel struct {
 	Redis *redis.Client
 }
 
-type CustomClaims struct {
-	UserID int64 `json:"user_id"`
-	jwt.StandardClaims
-}
+func generateRefreshToken(userID int64, sessionID, userAgent, ipAddress string, mfa bool) (*AuthorizationToken, error) {
+	token := &AuthorizationToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+		Expiry:    time.Now().Add(RefreshTokenTTL),
+		MFA:       mfa,
+	}
 
-func generateAuthenticationTokens(userID int64) (string, string, error) {
-	accessClaims := CustomClaims{
-		UserID: userID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(), // Expires in 24 hours
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessSecret := os.Getenv("ACCESS_SECRET")
-	accessTokenString, err := accessToken.SignedString([]byte(accessSecret))
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	refreshClaims := CustomClaims{
-		UserID: userID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24 * 30).Unix(), // Expires in 30 days
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshSecret := os.Getenv("REFRESH_SECRET")
-	refreshTokenString, err := refreshToken.SignedString([]byte(refreshSecret))
-	if err != nil {
-		return "", "", err
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	if token.SessionID == "" {
+		sessionBytes := make([]byte, 16)
+
+		_, err = rand.Read(sessionBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		token.SessionID = hex.EncodeToString(sessionBytes)
 	}
 
-	return accessTokenString, refreshTokenString, nil
+	return token, nil
 }
 
-func (m AuthorizationTokenModel) New(userID int64) (*AuthorizationToken, error) {
-	accessToken, refreshToken, err := generateAuthenticationTokens(userID)
+// New открывает новую сессию и выдает для нее первый refresh токен
+func (m AuthorizationTokenModel) New(userID int64, userAgent, ipAddress string, mfa bool) (*AuthorizationToken, error) {
+	token, err := generateRefreshToken(userID, "", userAgent, ipAddress, mfa)
 	if err != nil {
 		return nil, err
 	}
 
-	var tokens AuthorizationToken
-	tokens.UserID = userID
-	tokens.RefreshToken = refreshToken
-	tokens.AccessToken = accessToken
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	err = m.Insert(&tokens)
-	return &tokens, err
+	err = m.insert(ctx, m.DB, token)
+	return token, err
 }
 
-func (m AuthorizationTokenModel) Insert(tokens *AuthorizationToken) error {
-	err := m.Delete(tokens.UserID)
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
+func (m AuthorizationTokenModel) insert(ctx context.Context, q rowQuerier, token *AuthorizationToken) error {
 	query := `
-        INSERT INTO authorization_tokens (user_id, refresh_token)
-        VALUES ($1, $2)
-        RETURNING id
-    `
+		INSERT INTO authorization_tokens (user_id, session_id, token_hash, user_agent, ip_address, expiry, mfa)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	args := []any{token.UserID, token.SessionID, token.Hash, token.UserAgent, token.IPAddress, token.Expiry, token.MFA}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(&token.ID)
+}
 
-	args := []interface{}{tokens.UserID, tokens.RefreshToken}
+// Rotate обменивает refresh токен на новый в рамках той же сессии. Старый
+// токен помечается использованным; если его предъявят еще раз, сессия
+// целиком отзывается и возвращается ErrRefreshTokenReused.
+func (m AuthorizationTokenModel) Rotate(tokenPlaintext, userAgent, ipAddress string) (*AuthorizationToken, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	row := m.DB.QueryRowContext(ctx, query, args...)
-	err = row.Scan(&tokens.ID)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id        int64
+		userID    int64
+		sessionID string
+		expiry    time.Time
+		usedAt    sql.NullTime
+		revokedAt sql.NullTime
+		mfa       bool
+	)
+
+	query := `
+		SELECT id, user_id, session_id, expiry, used_at, revoked_at, mfa
+		FROM authorization_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	err = tx.QueryRowContext(ctx, query, tokenHash[:]).Scan(&id, &userID, &sessionID, &expiry, &usedAt, &revokedAt, &mfa)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	if revokedAt.Valid || time.Now().After(expiry) {
+		return nil, ErrRecordNotFound
+	}
+
+	if usedAt.Valid {
+		// Токен уже обменивали — его украли либо у нас, либо у владельца.
+		// Отзываем всю цепочку, чтобы ни одна из сторон не смогла продолжить.
+		revoked, err := m.revoke(ctx, tx, `session_id = $1`, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.markRevoked(revoked)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE authorization_tokens SET used_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateRefreshToken(userID, sessionID, userAgent, ipAddress, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.insert(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return token, nil
 }
 
-func (m AuthorizationTokenModel) Delete(userID int64) error {
+// GetAllForUser возвращает активные сессии пользователя, начиная с последней
+// активной. Время последней активности — момент последней ротации токена.
+func (m AuthorizationTokenModel) GetAllForUser(userID int64) ([]*model.Session, error) {
 	query := `
-		DELETE FROM authorization_tokens
-		WHERE user_id = $1
+		SELECT t.session_id, t.user_agent, t.ip_address,
+		       (SELECT MIN(s.created_at) FROM authorization_tokens s WHERE s.session_id = t.session_id),
+		       t.created_at, t.expiry
+		FROM authorization_tokens t
+		WHERE t.user_id = $1 AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expiry > NOW()
+		ORDER BY t.created_at DESC
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, userID)
-	return err
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		var session model.Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
 }
 
-func (m AuthorizationTokenModel) DeleteByToken(refreshToken string) error {
-	query := `
-		DELETE FROM authorization_tokens
-		WHERE refresh_token = $1
-	`
+// RevokeSession отзывает одну сессию пользователя. Возвращает false, если
+// активной сессии с таким идентификатором у пользователя нет.
+func (m AuthorizationTokenModel) RevokeSession(userID int64, sessionID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	revoked, err := m.revoke(ctx, m.DB, `user_id = $1 AND session_id = $2`, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
 
+	return len(revoked) > 0, m.markRevoked(revoked)
+}
+
+// RevokeAllForUser отзывает все сессии пользователя на всех устройствах
+func (m AuthorizationTokenModel) RevokeAllForUser(userID int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, refreshToken)
-	return err
+	revoked, err := m.revoke(ctx, m.DB, `user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return m.markRevoked(revoked)
 }
 
-func (m AuthorizationTokenModel) Get(userID int64) (string, error) {
-	query := `
-		SELECT refresh_token from authorization_tokens
-		WHERE authorization_tokens.user_id = $1
-	`
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// revoke помечает токены отозванными и возвращает затронутые сессии вместе
+// с последним сроком действия их токенов
+func (m AuthorizationTokenModel) revoke(ctx context.Context, q queryer, where string, args ...any) (map[string]time.Time, error) {
+	query := fmt.Sprintf(`
+		UPDATE authorization_tokens
+		SET revoked_at = NOW()
+		WHERE %s AND revoked_at IS NULL
+		RETURNING session_id, expiry
+	`, where)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revoked := make(map[string]time.Time)
+	for rows.Next() {
+		var (
+			sessionID string
+			expiry    time.Time
+		)
+		if err := rows.Scan(&sessionID, &expiry); err != nil {
+			return nil, err
+		}
+		if expiry.After(revoked[sessionID]) {
+			revoked[sessionID] = expiry
+		}
+	}
+
+	return revoked, rows.Err()
+}
 
-	var refreshToken string
+func revokedSessionKey(sessionID string) string {
+	return fmt.Sprintf("session:revoked:%s", sessionID)
+}
+
+// markRevoked запоминает отозванные сессии в Redis, чтобы уже выданные
+// access токены перестали приниматься. Access токен не живет дольше
+// refresh токена своей сессии, поэтому метку можно снять вместе с ним.
+func (m AuthorizationTokenModel) markRevoked(sessions map[string]time.Time) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	pipe := m.Redis.Pipeline()
+	for sessionID, expiry := range sessions {
+		ttl := time.Until(expiry)
+		if ttl <= 0 {
+			continue
+		}
+		pipe.Set(ctx, revokedSessionKey(sessionID), 1, ttl)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IsRevoked сообщает, была ли сессия отозвана
+func (m AuthorizationTokenModel) IsRevoked(sessionID string) (bool, error) {
+	n, err := m.Redis.Exists(context.Background(), revokedSessionKey(sessionID)).Result()
+	if err != nil {
+		return false, err
+	}
 
-	err := m.DB.QueryRow(query, userID).Scan(&refreshToken)
-	return refreshToken, err
+	return n > 0, nil
 }
@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/narxozer/graph/model"
+	"time"
+)
+
+// ErrBlocked — действие запрещено, потому что владелец контента
+// заблокировал пользователя
+var ErrBlocked = errors.New("blocked by user")
+
+// blockedCondition возвращает условие WHERE, скрывающее пользователей из
+// column, которых зритель (параметр $viewerArg) заблокировал или которые
+// заблокировали его. Для анонимного зрителя (id 0) ничего не скрывает.
+func blockedCondition(column string, viewerArg int) string {
+	return fmt.Sprintf(`NOT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $%[1]d AND blocked_id = %[2]s) OR (blocker_id = %[2]s AND blocked_id = $%[1]d)
+		)`, viewerArg, column)
+}
+
+// hiddenAuthorCondition дополнительно скрывает авторов, которых зритель
+// заглушил. Все выборки контента добавляют это условие, чтобы ограничения
+// нельзя было обойти другим запросом.
+func hiddenAuthorCondition(column string, viewerArg int) string {
+	return fmt.Sprintf(`NOT EXISTS (
+			SELECT 1 FROM user_mutes WHERE muter_id = $%[1]d AND muted_id = %[2]s
+		) AND %[3]s`, viewerArg, column, blockedCondition(column, viewerArg))
+}
+
+type BlockModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// Block блокирует userID и удаляет подписки между пользователями в обе
+// стороны. Возвращает ErrRecordNotFound, если пользователя нет.
+func (m BlockModel) Block(blockerID, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_blocks (blocker_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, blockerID, userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM follows
+		WHERE (follower_id = $1 AND followee_id = $2) OR (follower_id = $2 AND followee_id = $1)
+	`, blockerID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// Счетчики подписок и ленты обоих пользователей изменились
+	return m.Redis.Del(ctx,
+		fmt.Sprintf("user:%d", blockerID),
+		fmt.Sprintf("user:%d", userID),
+		feedKey(blockerID),
+		feedKey(userID),
+	).Err()
+}
+
+// Unblock снимает блокировку, если она была
+func (m BlockModel) Unblock(blockerID, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, userID)
+	if err != nil {
+		return err
+	}
+
+	return m.Redis.Del(ctx, feedKey(blockerID), feedKey(userID)).Err()
+}
+
+// Mute скрывает контент userID из списков muterID. Возвращает
+// ErrRecordNotFound, если пользователя нет.
+func (m BlockModel) Mute(muterID, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `
+		INSERT INTO user_mutes (muter_id, muted_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, muterID, userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	return m.Redis.Del(ctx, feedKey(muterID)).Err()
+}
+
+// Unmute снова показывает контент userID, если он был заглушен
+func (m BlockModel) Unmute(muterID, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`, muterID, userID)
+	if err != nil {
+		return err
+	}
+
+	return m.Redis.Del(ctx, feedKey(muterID)).Err()
+}
+
+// IsBlocked сообщает, заблокировал ли ownerID пользователя userID
+func (m BlockModel) IsBlocked(ownerID, userID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blocked bool
+
+	err := m.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2)
+	`, ownerID, userID).Scan(&blocked)
+
+	return blocked, err
+}
+
+// GetBlocked возвращает заблокированных пользователем, последние первыми
+func (m BlockModel) GetBlocked(userID int) ([]*model.User, error) {
+	return m.getUsers(`
+		SELECT u.id, u.email, u.name, u.lastname, u.role, u.image_url, u.additional_information, u.course, u.major, u.degree, u.faculty,
+		       u.activated, u.follow_approval_required, u.followers_count, u.following_count, u.created_at, u.updated_at,
+		       u.email_visibility, u.course_visibility, u.major_visibility, u.faculty_visibility, u.additional_information_visibility
+		FROM user_blocks b
+		INNER JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC
+	`, userID)
+}
+
+// GetMuted возвращает заглушенных пользователем, последние первыми
+func (m BlockModel) GetMuted(userID int) ([]*model.User, error) {
+	return m.getUsers(`
+		SELECT u.id, u.email, u.name, u.lastname, u.role, u.image_url, u.additional_information, u.course, u.major, u.degree, u.faculty,
+		       u.activated, u.follow_approval_required, u.followers_count, u.following_count, u.created_at, u.updated_at,
+		       u.email_visibility, u.course_visibility, u.major_visibility, u.faculty_visibility, u.additional_information_visibility
+		FROM user_mutes mu
+		INNER JOIN users u ON u.id = mu.muted_id
+		WHERE mu.muter_id = $1
+		ORDER BY mu.created_at DESC
+	`, userID)
+}
+
+func (m BlockModel) getUsers(query string, userID int) ([]*model.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*model.User{}
+	for rows.Next() {
+		var user model.User
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Name,
+			&user.Lastname,
+			&user.Role,
+			&user.ImageURL,
+			&user.AdditionalInformation,
+			&user.Course,
+			&user.Major,
+			&user.Degree,
+			&user.Faculty,
+			&user.Activated,
+			&user.RequiresFollowApproval,
+			&user.FollowersCount,
+			&user.FollowingCount,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Privacy.Email,
+			&user.Privacy.Course,
+			&user.Privacy.Major,
+			&user.Privacy.Faculty,
+			&user.Privacy.AdditionalInformation,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
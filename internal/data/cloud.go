@@ -1,18 +1,32 @@
 package data
 
 import (
-	"cloud.google.com/go/storage"
 	"github.com/olzzhas/narxozer/internal/image"
+	"strings"
 )
 
 type Storages struct {
-	ProfileImage image.ProfileImageStorage
-	PostImage    image.PostImageStorage
+	Images image.Storage
+	// BaseURL — внешний адрес API, например https://api.narxozer.kz
+	BaseURL string
 }
 
-func NewStorages(client *storage.Client) Storages {
+func NewStorages(images image.Storage, baseURL string) Storages {
 	return Storages{
-		ProfileImage: image.ProfileImageStorage{Client: client},
-		PostImage:    image.PostImageStorage{Client: client},
+		Images:  images,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
 	}
 }
+
+// ImageURL возвращает постоянную ссылку на файл. Сам файл не публичен:
+// ссылка ведет на API, который перенаправляет на свежую подписанную ссылку
+// хранилища.
+func (s Storages) ImageURL(key string) string {
+	return s.BaseURL + "/v1/images/" + key
+}
+
+// ImageKey возвращает ключ файла по постоянной ссылке ImageURL. Для
+// внешних ссылок ok равно false.
+func (s Storages) ImageKey(url string) (key string, ok bool) {
+	return strings.CutPrefix(url, s.ImageURL(""))
+}
@@ -33,13 +33,18 @@ func (m ClubModel) Insert(club *model.Club, id int) (*model.Club, error) {
 	return club, nil
 }
 
-func (m ClubModel) GetAll() ([]*model.Club, error) {
-	query := `
+func (m ClubModel) GetAll(filters CursorFilters) (*model.ClubConnection, error) {
+	where, orderBy, args := filters.keyset("id", false, 1)
+
+	query := fmt.Sprintf(`
 		SELECT id, name, description, image_url, creator_id, created_at
 		FROM clubs
-	`
+		WHERE %s
+		ORDER BY %s
+		LIMIT %d
+	`, where, orderBy, filters.limit())
 
-	rows, err := m.DB.Query(query)
+	rows, err := m.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -67,8 +72,24 @@ func (m ClubModel) GetAll() ([]*model.Club, error) {
 		return nil, err
 	}
 
-	return clubs, nil
+	var totalCount int
+	err = m.DB.QueryRow(`SELECT count(*) FROM clubs`).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	clubs, pageInfo := paginate(filters, clubs, func(club *model.Club) string {
+		return EncodeCursor("", club.ID)
+	})
+
+	edges := make([]*model.ClubEdge, 0, len(clubs))
+	for _, club := range clubs {
+		edges = append(edges, &model.ClubEdge{Cursor: EncodeCursor("", club.ID), Node: club})
+	}
+
+	return &model.ClubConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
 }
+
 func (m ClubModel) GetCachedMembers(clubID int) ([]*model.User, error) {
 	cacheKey := fmt.Sprintf("club:%d:members", clubID)
 
@@ -108,7 +129,7 @@ func (m ClubModel) GetCachedMembers(clubID int) ([]*model.User, error) {
 
 func (m ClubModel) GetMembers(clubID int) ([]*model.User, error) {
 	query := `
-		SELECT u.id, u.email, u.name, u.lastname, u.image_url
+		SELECT u.id, u.email, u.name, u.lastname, u.image_url, u.email_visibility
 		FROM club_members cm
 		JOIN users u ON cm.user_id = u.id
 		WHERE cm.club_id = $1
@@ -129,6 +150,7 @@ func (m ClubModel) GetMembers(clubID int) ([]*model.User, error) {
 			&member.Name,
 			&member.Lastname,
 			&member.ImageURL,
+			&member.Privacy.Email,
 		)
 		if err != nil {
 			return nil, err
@@ -266,6 +288,38 @@ func (m ClubModel) IsAdmin(clubId, userId int) bool {
 	return exists
 }
 
+// GetRole возвращает старшую роль пользователя в клубе или nil, если он
+// в клубе не состоит
+func (m ClubModel) GetRole(clubId, userId int) (*model.ClubRole, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM clubs WHERE id = $1 AND creator_id = $2),
+			EXISTS (SELECT 1 FROM club_admins WHERE club_id = $1 AND user_id = $2),
+			EXISTS (SELECT 1 FROM club_members WHERE club_id = $1 AND user_id = $2)
+	`
+
+	var isCreator, isAdmin, isMember bool
+
+	err := m.DB.QueryRow(query, clubId, userId).Scan(&isCreator, &isAdmin, &isMember)
+	if err != nil {
+		return nil, err
+	}
+
+	var role model.ClubRole
+	switch {
+	case isCreator:
+		role = model.ClubRoleCreator
+	case isAdmin:
+		role = model.ClubRoleAdmin
+	case isMember:
+		role = model.ClubRoleMember
+	default:
+		return nil, nil
+	}
+
+	return &role, nil
+}
+
 func (m ClubModel) IsCreator(clubId, userId int) (bool, error) {
 	query := `SELECT COUNT(*) FROM clubs WHERE id = $1 AND creator_id = $2`
 	var count int
@@ -2,6 +2,8 @@ package data
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/olzzhas/narxozer/graph/model"
 )
@@ -11,10 +13,22 @@ type CommentModel struct {
 	Redis *redis.Client
 }
 
+// Insert сохраняет комментарий. Возвращает ErrBlocked, если автор поста или
+// топика либо родительского комментария заблокировал комментатора.
 func (m CommentModel) Insert(comment *model.Comment) (*model.Comment, error) {
 	query := `
 		INSERT INTO comments (content, image_url, entity_id, entity_type, author_id, parent_id, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, now())
+		SELECT $1, $2, $3::int, $4::text, $5::int, $6::int, now()
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE blocked_id = $5::int AND blocker_id IN (
+				SELECT author_id FROM posts WHERE $4::text = 'post' AND id = $3::int
+				UNION ALL
+				SELECT author_id FROM topics WHERE $4::text = 'topic' AND id = $3::int
+				UNION ALL
+				SELECT author_id FROM comments WHERE id = $6::int
+			)
+		)
 		RETURNING id, created_at
 	`
 
@@ -22,24 +36,32 @@ func (m CommentModel) Insert(comment *model.Comment) (*model.Comment, error) {
 
 	err := m.DB.QueryRow(query, args...).Scan(&comment.ID, &comment.CreatedAt)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlocked
+		}
 		return nil, err
 	}
 
 	return comment, nil
 }
 
-func (m CommentModel) GetAllByPost(id int) ([]*model.Comment, error) {
-	query := `
+// GetByEntityID возвращает страницу комментариев к посту или топику без
+// авторов, скрытых от зрителя viewerID блокировкой или заглушением
+func (m CommentModel) GetByEntityID(viewerID, entityID int, entityType string, filters CursorFilters) (*model.CommentConnection, error) {
+	conditions := "entity_id = $1 AND entity_type = $2 AND " + hiddenAuthorCondition("author_id", 3)
+	where, orderBy, args := filters.keyset("id", false, 4)
+
+	query := fmt.Sprintf(`
 		SELECT id, content, image_url, entity_id, entity_type, author_id, parent_id, created_at, updated_at, likes
 		FROM comments
-		WHERE entity_id = $1
-	`
+		WHERE %s AND %s
+		ORDER BY %s
+		LIMIT %d`, conditions, where, orderBy, filters.limit())
 
-	rows, err := m.DB.Query(query, id)
+	rows, err := m.DB.Query(query, append([]any{entityID, entityType, viewerID}, args...)...)
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
 	var comments []*model.Comment
@@ -68,47 +90,77 @@ func (m CommentModel) GetAllByPost(id int) ([]*model.Comment, error) {
 		return nil, err
 	}
 
-	return comments, nil
+	var totalCount int
+	err = m.DB.QueryRow(`SELECT count(*) FROM comments WHERE `+conditions, entityID, entityType, viewerID).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, pageInfo := paginate(filters, comments, func(comment *model.Comment) string {
+		return EncodeCursor("", comment.ID)
+	})
+
+	edges := make([]*model.CommentEdge, 0, len(comments))
+	for _, comment := range comments {
+		edges = append(edges, &model.CommentEdge{Cursor: EncodeCursor("", comment.ID), Node: comment})
+	}
+
+	return &model.CommentConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
 }
 
-func (m CommentModel) GetByEntityID(topicID int, entityType string) ([]*model.Comment, error) {
+// GetByID возвращает комментарий или nil, если его нет либо его автор и
+// зритель viewerID заблокировали друг друга
+func (m CommentModel) GetByID(viewerID, id int) (*model.Comment, error) {
 	query := `
-		SELECT id, content, entity_id, entity_type, author_id, parent_id, created_at, updated_at, likes
+		SELECT id, content, image_url, entity_id, entity_type, author_id, parent_id, created_at, updated_at, COALESCE(likes, 0)
 		FROM comments
-		WHERE entity_id = $1 AND entity_type = $2
-		ORDER BY created_at ASC`
-
-	rows, err := m.DB.Query(query, topicID, entityType)
+		WHERE id = $1 AND ` + blockedCondition("author_id", 2)
+
+	var comment model.Comment
+	comment.Author = &model.User{}
+	err := m.DB.QueryRow(query, id, viewerID).Scan(
+		&comment.ID,
+		&comment.Content,
+		&comment.ImageURL,
+		&comment.EntityID,
+		&comment.EntityType,
+		&comment.Author.ID,
+		&comment.ParentID,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.Likes,
+	)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
 		return nil, err
 	}
-	defer rows.Close()
 
-	var comments []*model.Comment
-	for rows.Next() {
-		var comment model.Comment
-		err := rows.Scan(
-			&comment.ID,
-			&comment.Content,
-			&comment.EntityID,
-			&comment.EntityType,
-			&comment.Author.ID,
-			&comment.ParentID,
-			&comment.CreatedAt,
-			&comment.UpdatedAt,
-			&comment.Likes,
-		)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, &comment)
-	}
+	return &comment, nil
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
+// Update сохраняет текст и изображение комментария
+func (m CommentModel) Update(comment *model.Comment) error {
+	query := `
+		UPDATE comments
+		SET content = $1, image_url = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING updated_at`
+
+	return m.DB.QueryRow(query, comment.Content, comment.ImageURL, comment.ID).Scan(&comment.UpdatedAt)
+}
+
+// ToggleLike ставит или снимает лайк пользователя на комментарии.
+// Возвращает ErrRecordNotFound или ErrBlocked, как и PostModel.ToggleLike.
+func (m CommentModel) ToggleLike(userID, id int) error {
+	return toggleLike(m.DB, "comments", "comment", userID, id)
+}
 
-	return comments, nil
+// Delete удаляет комментарий вместе с ответами на него
+func (m CommentModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM comments WHERE id = $1`, id)
+	return err
 }
 
 func (m CommentModel) UpdatePostComment() {
@@ -0,0 +1,352 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// Состояния архива с данными пользователя
+const (
+	DataExportStatusPending = "pending"
+	DataExportStatusRunning = "running"
+	DataExportStatusReady   = "ready"
+	DataExportStatusFailed  = "failed"
+)
+
+const (
+	// DataExportTTL — сколько готовый архив доступен по ссылке из письма
+	DataExportTTL = 7 * 24 * time.Hour
+	// dataExportInterval — как часто пользователь может заказывать архив
+	dataExportInterval = 24 * time.Hour
+	// dataExportTimeout — через сколько зависшая сборка начинается заново,
+	// например после перезапуска сервера
+	dataExportTimeout = time.Hour
+)
+
+// ErrExportTooSoon — предыдущий архив заказан меньше суток назад или еще
+// собирается
+var ErrExportTooSoon = errors.New("data export requested too recently")
+
+type DataExport struct {
+	ID          int64
+	UserID      int64
+	Status      string
+	ObjectKey   *string
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time
+}
+
+// ExportSection — один файл архива: JSON-массив или объект
+type ExportSection struct {
+	Name string
+	Data json.RawMessage
+}
+
+// exportSections перечисляет содержимое архива. У пользователя нет отметок
+// об участии в событиях, поэтому выгружаются события его клубов.
+var exportSections = []struct {
+	name  string
+	query string
+}{
+	{"profile", `
+		SELECT id, email, name, lastname, role, image_url, additional_information, course, major, degree, faculty,
+		       activated, follow_approval_required, email_visibility, course_visibility, major_visibility,
+		       faculty_visibility, additional_information_visibility, created_at, updated_at
+		FROM users
+		WHERE id = $1`},
+	{"posts", `
+		SELECT id, title, content, image_url, club_id, likes, comments_count, created_at, updated_at
+		FROM posts
+		WHERE author_id = $1`},
+	{"topics", `
+		SELECT id, title, content, image_url, likes, comments_count, created_at, updated_at
+		FROM topics
+		WHERE author_id = $1`},
+	{"comments", `
+		SELECT id, entity_type, entity_id, parent_id, content, image_url, likes, created_at, updated_at
+		FROM comments
+		WHERE author_id = $1`},
+	{"likes", `
+		SELECT entity_type, entity_id, created_at
+		FROM likes
+		WHERE user_id = $1`},
+	{"clubs", `
+		SELECT c.id, c.name,
+		       CASE
+		           WHEN c.creator_id = $1 THEN 'CREATOR'
+		           WHEN EXISTS (SELECT 1 FROM club_admins a WHERE a.club_id = c.id AND a.user_id = $1) THEN 'ADMIN'
+		           ELSE 'MEMBER'
+		       END AS role
+		FROM clubs c
+		WHERE c.creator_id = $1
+		   OR c.id IN (SELECT club_id FROM club_members WHERE user_id = $1)
+		   OR c.id IN (SELECT club_id FROM club_admins WHERE user_id = $1)`},
+	{"events", `
+		SELECT e.id, e.club_id, e.title, e.description, e.image_url, e.date, e.created_at
+		FROM events e
+		WHERE e.club_id IN (SELECT club_id FROM club_members WHERE user_id = $1)
+		   OR e.club_id IN (SELECT club_id FROM club_admins WHERE user_id = $1)
+		   OR e.club_id IN (SELECT id FROM clubs WHERE creator_id = $1)`},
+	{"follows", `
+		SELECT follower_id, followee_id, status, created_at
+		FROM follows
+		WHERE follower_id = $1 OR followee_id = $1`},
+	{"security_events", `
+		SELECT event_type, ip_address, user_agent, created_at
+		FROM security_events
+		WHERE user_id = $1`},
+}
+
+type DataExportModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// New ставит сборку архива в очередь. Возвращает ErrExportTooSoon, если
+// пользователь уже заказывал архив в последние сутки.
+func (m DataExportModel) New(userID int64) (*DataExport, error) {
+	query := `
+		INSERT INTO data_exports (user_id)
+		SELECT $1
+		WHERE NOT EXISTS (
+			SELECT 1 FROM data_exports
+			WHERE user_id = $1 AND (created_at > $2 OR status IN ('pending', 'running'))
+		)
+		RETURNING id, status, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	export := &DataExport{UserID: userID}
+
+	err := m.DB.QueryRowContext(ctx, query, userID, time.Now().Add(-dataExportInterval)).Scan(
+		&export.ID,
+		&export.Status,
+		&export.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrExportTooSoon
+		default:
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+// GetAllForUser возвращает архивы пользователя, новые первыми
+func (m DataExportModel) GetAllForUser(userID int64) ([]*DataExport, error) {
+	query := `
+		SELECT id, user_id, status, object_key, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*DataExport{}
+	for rows.Next() {
+		var export DataExport
+		err := rows.Scan(
+			&export.ID,
+			&export.UserID,
+			&export.Status,
+			&export.ObjectKey,
+			&export.CreatedAt,
+			&export.CompletedAt,
+			&export.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, &export)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+// Claim забирает из очереди следующий архив для сборки или возвращает nil,
+// если очередь пуста. Несколько экземпляров API не возьмут один архив.
+func (m DataExportModel) Claim() (*DataExport, error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'running', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = 'pending' OR (status = 'running' AND started_at < $1)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var export DataExport
+
+	err := m.DB.QueryRowContext(ctx, query, time.Now().Add(-dataExportTimeout)).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &export, nil
+}
+
+// Sections собирает данные пользователя для архива
+func (m DataExportModel) Sections(userID int64) ([]ExportSection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sections := make([]ExportSection, 0, len(exportSections))
+	for _, section := range exportSections {
+		query := fmt.Sprintf(`SELECT COALESCE(json_agg(s), '[]') FROM (%s) s`, section.query)
+
+		var data []byte
+
+		err := m.DB.QueryRowContext(ctx, query, userID).Scan(&data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", section.name, err)
+		}
+
+		sections = append(sections, ExportSection{Name: section.name, Data: data})
+	}
+
+	return sections, nil
+}
+
+// Complete отмечает архив готовым и возвращает токен для ссылки на
+// скачивание. Открытый текст токена нигде не хранится.
+func (m DataExportModel) Complete(id int64, objectKey string) (string, time.Time, error) {
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(plaintext))
+	expiresAt := time.Now().Add(DataExportTTL)
+
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', token_hash = $2, object_key = $3, completed_at = NOW(), expires_at = $4
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, id, hash[:], objectKey, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return plaintext, expiresAt, nil
+}
+
+// Fail отмечает, что архив собрать не удалось
+func (m DataExportModel) Fail(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `UPDATE data_exports SET status = 'failed', completed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// GetObjectKeyForToken возвращает ключ готового и не просроченного архива
+// по токену из письма или ErrRecordNotFound
+func (m DataExportModel) GetObjectKeyForToken(tokenPlaintext string) (string, error) {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT object_key
+		FROM data_exports
+		WHERE token_hash = $1 AND status = 'ready' AND expires_at > NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var objectKey string
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(&objectKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return objectKey, nil
+}
+
+// DeleteExpired удаляет записи о просроченных архивах и возвращает ключи
+// их файлов
+func (m DataExportModel) DeleteExpired() ([]string, error) {
+	query := `
+		DELETE FROM data_exports
+		WHERE expires_at <= NOW() OR (status = 'failed' AND completed_at < $1)
+		RETURNING object_key
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, time.Now().Add(-dataExportInterval))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key sql.NullString
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if key.Valid {
+			keys = append(keys, key.String)
+		}
+	}
+
+	return keys, rows.Err()
+}
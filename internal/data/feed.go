@@ -0,0 +1,158 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/narxozer/graph/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FeedKindFollowedPost     = "followed_post"
+	FeedKindClubAnnouncement = "club_announcement"
+	FeedKindClubEvent        = "club_event"
+	FeedKindHotTopic         = "hot_topic"
+)
+
+const (
+	feedTimelineSize = 500
+	feedHotTopics    = 10
+	feedCacheTTL     = 2 * time.Minute
+)
+
+type FeedItem struct {
+	Kind   string
+	ID     int
+	Cursor string
+}
+
+type FeedModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func feedKey(userID int) string {
+	return fmt.Sprintf("feed:%d", userID)
+}
+
+// build собирает ленту пользователя одним fan-out-on-read запросом и кладёт
+// её в sorted set Redis: member — "kind:id", score — время публикации в мс.
+func (m FeedModel) build(ctx context.Context, userID int) error {
+	query := `
+		SELECT kind, id, created_at FROM (
+			SELECT 'followed_post' AS kind, p.id, p.created_at
+			FROM posts p
+			WHERE p.club_id IS NULL
+			AND (p.author_id = $1 OR p.author_id IN (SELECT followee_id FROM follows WHERE follower_id = $1 AND status = 'accepted'))
+			AND ` + hiddenAuthorCondition("p.author_id", 1) + `
+			UNION ALL
+			SELECT 'club_announcement', p.id, p.created_at
+			FROM posts p
+			WHERE p.club_id IN (SELECT club_id FROM club_members WHERE user_id = $1)
+			AND ` + hiddenAuthorCondition("p.author_id", 1) + `
+			UNION ALL
+			SELECT 'club_event', e.id, e.created_at
+			FROM events e
+			WHERE e.club_id IN (SELECT club_id FROM club_members WHERE user_id = $1)
+			UNION ALL
+			SELECT 'hot_topic', t.id, t.created_at
+			FROM topics t
+			WHERE t.id = ANY($3)
+			AND ` + hiddenAuthorCondition("t.author_id", 1) + `
+		) items
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	hotTopics, err := hotTopicIDs(ctx, m.Redis, feedHotTopics)
+	if err != nil {
+		return err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, feedTimelineSize, pq.Array(hotTopics))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var members []*redis.Z
+	for rows.Next() {
+		var (
+			kind      string
+			id        int
+			createdAt time.Time
+		)
+		err := rows.Scan(&kind, &id, &createdAt)
+		if err != nil {
+			return err
+		}
+		members = append(members, &redis.Z{
+			Score:  float64(createdAt.UnixMilli()),
+			Member: fmt.Sprintf("%s:%d", kind, id),
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	key := feedKey(userID)
+
+	pipe := m.Redis.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(members) > 0 {
+		pipe.ZAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, feedCacheTTL)
+	}
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+// Get возвращает страницу ленты пользователя, при необходимости пересобирая
+// закешированную ленту.
+func (m FeedModel) Get(userID int, filters CursorFilters) ([]*FeedItem, *model.PageInfo, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	key := feedKey(userID)
+
+	exists, err := m.Redis.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if exists == 0 {
+		err = m.build(ctx, userID)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	entries, pageInfo, totalCount, err := zsetPage(ctx, m.Redis, key, filters)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	items := make([]*FeedItem, 0, len(entries))
+	for _, entry := range entries {
+		kind, rawID, _ := strings.Cut(entry.Member, ":")
+		id, err := strconv.Atoi(rawID)
+		if err != nil {
+			continue
+		}
+
+		items = append(items, &FeedItem{Kind: kind, ID: id, Cursor: entry.Cursor})
+	}
+
+	return items, pageInfo, totalCount, nil
+}
+
+// Invalidate сбрасывает закешированную ленту, например после вступления в клуб.
+func (m FeedModel) Invalidate(userID int) error {
+	return m.Redis.Del(context.Background(), feedKey(userID)).Err()
+}
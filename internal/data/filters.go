@@ -1,8 +1,14 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/olzzhas/narxozer/graph/model"
 	"github.com/olzzhas/narxozer/internal/validator"
 	"math"
+	"slices"
 	"strings"
 )
 
@@ -35,6 +41,10 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 10000, "page_size", "must be a maximum of 100")
 
+	ValidateSort(v, f)
+}
+
+func ValidateSort(v *validator.Validator, f Filters) {
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
 }
 
@@ -69,3 +79,178 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 		TotalRecords: totalRecords,
 	}
 }
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorFilters описывает запрос страницы в стиле Relay: first/after листает
+// вперёд, last/before — назад.
+type CursorFilters struct {
+	First  *int
+	After  *string
+	Last   *int
+	Before *string
+}
+
+type cursor struct {
+	Value string  `json:"v,omitempty"`
+	Score float64 `json:"s,omitempty"`
+	ID    int     `json:"id,omitempty"`
+}
+
+// EncodeCursor упаковывает значение колонки сортировки и id записи в
+// непрозрачную для клиента строку.
+func EncodeCursor(value string, id int) string {
+	js, _ := json.Marshal(cursor{Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// EncodeScoreCursor — курсор для выборок из sorted set Redis.
+func EncodeScoreCursor(score float64, member string) string {
+	js, _ := json.Marshal(cursor{Value: member, Score: score})
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 0 || (c.ID == 0 && c.Value == "") {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+func ValidateCursorFilters(v *validator.Validator, f CursorFilters) {
+	v.Check(f.First == nil || f.Last == nil, "last", "must not be combined with first")
+	v.Check(f.After == nil || f.Before == nil, "before", "must not be combined with after")
+
+	if f.First != nil {
+		v.Check(*f.First >= 0, "first", "must not be negative")
+		v.Check(*f.First <= MaxPageSize, "first", "must be a maximum of 100")
+	}
+
+	if f.Last != nil {
+		v.Check(*f.Last >= 0, "last", "must not be negative")
+		v.Check(*f.Last <= MaxPageSize, "last", "must be a maximum of 100")
+	}
+
+	if f.After != nil {
+		_, err := decodeCursor(*f.After)
+		v.Check(err == nil, "after", "must be a cursor returned by the server")
+	}
+
+	if f.Before != nil {
+		_, err := decodeCursor(*f.Before)
+		v.Check(err == nil, "before", "must be a cursor returned by the server")
+	}
+}
+
+func (f CursorFilters) backward() bool {
+	return f.Last != nil || (f.First == nil && f.Before != nil)
+}
+
+func (f CursorFilters) pageSize() int {
+	switch {
+	case f.First != nil:
+		return *f.First
+	case f.Last != nil:
+		return *f.Last
+	default:
+		return DefaultPageSize
+	}
+}
+
+// limit запрашивает на одну строку больше размера страницы, чтобы узнать,
+// есть ли записи дальше.
+func (f CursorFilters) limit() int {
+	return f.pageSize() + 1
+}
+
+func (f CursorFilters) cursor() *cursor {
+	s := f.After
+	if f.backward() {
+		s = f.Before
+	}
+
+	if s == nil {
+		return nil
+	}
+
+	c, err := decodeCursor(*s)
+	if err != nil {
+		return nil
+	}
+
+	return c
+}
+
+// keyset возвращает условие WHERE и выражение ORDER BY для выборки страницы
+// при сортировке по column (при равенстве — по id). Плейсхолдеры нумеруются
+// начиная с argPos.
+func (f CursorFilters) keyset(column string, desc bool, argPos int) (string, string, []any) {
+	if f.backward() {
+		desc = !desc
+	}
+
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+
+	c := f.cursor()
+
+	if column == "id" {
+		orderBy := fmt.Sprintf("id %s", direction)
+		if c == nil {
+			return "TRUE", orderBy, nil
+		}
+		return fmt.Sprintf("id %s $%d", op, argPos), orderBy, []any{c.ID}
+	}
+
+	orderBy := fmt.Sprintf("%s %s, id %s", column, direction, direction)
+	if c == nil {
+		return "TRUE", orderBy, nil
+	}
+
+	where := fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, op, argPos, argPos+1)
+	return where, orderBy, []any{c.Value, c.ID}
+}
+
+// paginate отбрасывает лишнюю строку, полученную keyset-запросом,
+// восстанавливает порядок для last/before и заполняет PageInfo.
+func paginate[T any](f CursorFilters, items []T, cursorOf func(T) string) ([]T, *model.PageInfo) {
+	hasMore := len(items) > f.pageSize()
+	if hasMore {
+		items = items[:f.pageSize()]
+	}
+
+	pageInfo := &model.PageInfo{}
+
+	if f.backward() {
+		slices.Reverse(items)
+		pageInfo.HasPreviousPage = hasMore
+		pageInfo.HasNextPage = f.Before != nil
+	} else {
+		pageInfo.HasNextPage = hasMore
+		pageInfo.HasPreviousPage = f.After != nil
+	}
+
+	if len(items) > 0 {
+		start := cursorOf(items[0])
+		end := cursorOf(items[len(items)-1])
+		pageInfo.StartCursor = &start
+		pageInfo.EndCursor = &end
+	}
+
+	return items, pageInfo
+}
@@ -0,0 +1,294 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/olzzhas/narxozer/graph/model"
+	"time"
+)
+
+// Состояния подписки в таблице follows
+const (
+	FollowStatusAccepted = "accepted"
+	FollowStatusPending  = "pending"
+)
+
+type FollowModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// Follow подписывает followerID на followeeID. На закрытый аккаунт создается
+// заявка в статусе pending; повторный вызов не меняет уже существующую
+// подписку. Возвращает ErrRecordNotFound, если пользователя нет или
+// пользователи заблокировали друг друга.
+func (m FollowModel) Follow(followerID, followeeID int) (string, error) {
+	query := `
+		INSERT INTO follows (follower_id, followee_id, status)
+		SELECT $1, u.id, CASE WHEN u.follow_approval_required THEN 'pending' ELSE 'accepted' END
+		FROM users u
+		WHERE u.id = $2 AND ` + blockedCondition("u.id", 1) + `
+		ON CONFLICT (follower_id, followee_id) DO UPDATE SET status = follows.status
+		RETURNING status
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var status string
+
+	err := m.DB.QueryRowContext(ctx, query, followerID, followeeID).Scan(&status)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return status, m.invalidate(ctx, followerID, followeeID)
+}
+
+// Unfollow удаляет подписку или заявку на нее, если они есть
+func (m FollowModel) Unfollow(followerID, followeeID int) error {
+	query := `
+		DELETE FROM follows
+		WHERE follower_id = $1 AND followee_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+
+	return m.invalidate(ctx, followerID, followeeID)
+}
+
+// Approve одобряет заявку followerID. Возвращает ErrRecordNotFound, если
+// ожидающей заявки нет.
+func (m FollowModel) Approve(followeeID, followerID int) error {
+	query := `
+		UPDATE follows
+		SET status = 'accepted'
+		WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'
+	`
+
+	return m.resolveRequest(query, followerID, followeeID)
+}
+
+// Reject отклоняет заявку followerID. Возвращает ErrRecordNotFound, если
+// ожидающей заявки нет.
+func (m FollowModel) Reject(followeeID, followerID int) error {
+	query := `
+		DELETE FROM follows
+		WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'
+	`
+
+	return m.resolveRequest(query, followerID, followeeID)
+}
+
+func (m FollowModel) resolveRequest(query string, followerID, followeeID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return m.invalidate(ctx, followerID, followeeID)
+}
+
+// AcceptAllPending одобряет все заявки пользователя; вызывается, когда он
+// открывает аккаунт
+func (m FollowModel) AcceptAllPending(followeeID int) error {
+	query := `
+		UPDATE follows
+		SET status = 'accepted'
+		WHERE followee_id = $1 AND status = 'pending'
+		RETURNING follower_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, followeeID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var followerIDs []int
+	for rows.Next() {
+		var followerID int
+		if err := rows.Scan(&followerID); err != nil {
+			return err
+		}
+		followerIDs = append(followerIDs, followerID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, followerID := range followerIDs {
+		err = m.invalidate(ctx, followerID, followeeID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status возвращает состояние подписки followerID на followeeID или пустую
+// строку, если подписки нет
+func (m FollowModel) Status(followerID, followeeID int) (string, error) {
+	query := `
+		SELECT status
+		FROM follows
+		WHERE follower_id = $1 AND followee_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var status string
+
+	err := m.DB.QueryRowContext(ctx, query, followerID, followeeID).Scan(&status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return status, nil
+}
+
+// GetFollowers возвращает одобренных подписчиков пользователя, новые первыми
+func (m FollowModel) GetFollowers(userID int, page CursorFilters) (*model.UserConnection, error) {
+	return m.getUsers("f.follower_id", "f.followee_id = $1 AND f.status = 'accepted'", userID, page)
+}
+
+// GetFollowing возвращает пользователей, на которых userID подписан
+func (m FollowModel) GetFollowing(userID int, page CursorFilters) (*model.UserConnection, error) {
+	return m.getUsers("f.followee_id", "f.follower_id = $1 AND f.status = 'accepted'", userID, page)
+}
+
+// GetRequests возвращает авторов ожидающих заявок на подписку к userID
+func (m FollowModel) GetRequests(userID int, page CursorFilters) (*model.UserConnection, error) {
+	return m.getUsers("f.follower_id", "f.followee_id = $1 AND f.status = 'pending'", userID, page)
+}
+
+// getUsers выбирает страницу пользователей из follows, соединяя users по
+// колонке joinColumn. Сортировка — по времени подписки.
+func (m FollowModel) getUsers(joinColumn, conditions string, userID int, page CursorFilters) (*model.UserConnection, error) {
+	where, orderBy, keysetArgs := page.keyset("f.created_at", true, 2)
+
+	query := fmt.Sprintf(`
+		SELECT u.id, u.email, u.name, u.lastname, u.role, u.image_url, u.additional_information, u.course, u.major, u.degree, u.faculty,
+		       u.activated, u.follow_approval_required, u.followers_count, u.following_count, u.created_at, u.updated_at,
+		       u.email_visibility, u.course_visibility, u.major_visibility, u.faculty_visibility, u.additional_information_visibility, f.created_at
+		FROM follows f
+		INNER JOIN users u ON u.id = %s
+		WHERE %s AND %s
+		ORDER BY %s
+		LIMIT %d
+	`, joinColumn, conditions, where, orderBy, page.limit())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, append([]any{userID}, keysetArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type follow struct {
+		user       *model.User
+		followedAt time.Time
+	}
+
+	var follows []follow
+	for rows.Next() {
+		var user model.User
+		var followedAt time.Time
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Name,
+			&user.Lastname,
+			&user.Role,
+			&user.ImageURL,
+			&user.AdditionalInformation,
+			&user.Course,
+			&user.Major,
+			&user.Degree,
+			&user.Faculty,
+			&user.Activated,
+			&user.RequiresFollowApproval,
+			&user.FollowersCount,
+			&user.FollowingCount,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Privacy.Email,
+			&user.Privacy.Course,
+			&user.Privacy.Major,
+			&user.Privacy.Faculty,
+			&user.Privacy.AdditionalInformation,
+			&followedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		follows = append(follows, follow{user: &user, followedAt: followedAt})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var totalCount int
+	err = m.DB.QueryRowContext(ctx, `SELECT count(*) FROM follows f WHERE `+conditions, userID).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	cursorOf := func(f follow) string {
+		return EncodeCursor(f.followedAt.Format(time.RFC3339Nano), f.user.ID)
+	}
+
+	follows, pageInfo := paginate(page, follows, cursorOf)
+
+	edges := make([]*model.UserEdge, 0, len(follows))
+	for _, f := range follows {
+		edges = append(edges, &model.UserEdge{Cursor: cursorOf(f), Node: f.user})
+	}
+
+	return &model.UserConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
+}
+
+// invalidate сбрасывает закешированные профили (в них хранятся счетчики)
+// и ленту подписчика
+func (m FollowModel) invalidate(ctx context.Context, followerID, followeeID int) error {
+	return m.Redis.Del(ctx,
+		fmt.Sprintf("user:%d", followerID),
+		fmt.Sprintf("user:%d", followeeID),
+		feedKey(followerID),
+	).Err()
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// toggleLike ставит лайк пользователя userID на запись id таблицы table или
+// снимает уже поставленный. Возвращает ErrRecordNotFound, если записи нет,
+// и ErrBlocked, если ее автор и пользователь заблокировали друг друга.
+func toggleLike(db *sql.DB, table, entityType string, userID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки упорядочивает одновременные лайки одной записи
+	var allowed bool
+
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE id = $1
+		FOR UPDATE`, blockedCondition("author_id", 2), table), id, userID).Scan(&allowed)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if !allowed {
+		return ErrBlocked
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM likes
+		WHERE user_id = $1 AND entity_id = $2 AND entity_type = $3
+	`, userID, id, entityType)
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	delta := 1
+	if removed > 0 {
+		delta = -1
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO likes (user_id, entity_id, entity_type)
+			VALUES ($1, $2, $3)
+		`, userID, id, entityType)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE %s
+		SET likes = GREATEST(COALESCE(likes, 0) + $2, 0)
+		WHERE id = $1`, table), id, delta)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
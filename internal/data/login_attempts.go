@@ -0,0 +1,174 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loginThrottle описывает экспоненциальную задержку после неудачных попыток:
+// первые free попыток бесплатны, затем каждая следующая удваивает паузу
+type loginThrottle struct {
+	kind      string
+	free      int64
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	// window — через сколько после последней ошибки счетчик забывается
+	window time.Duration
+}
+
+var (
+	accountThrottle = loginThrottle{kind: "account", free: 3, baseDelay: time.Second, maxDelay: 5 * time.Minute, window: time.Hour}
+	ipThrottle      = loginThrottle{kind: "ip", free: 10, baseDelay: time.Second, maxDelay: 10 * time.Minute, window: time.Hour}
+)
+
+const (
+	// AccountLockThreshold — после стольких неудачных попыток подряд аккаунт
+	// временно блокируется
+	AccountLockThreshold = 10
+	AccountLockDuration  = 15 * time.Minute
+)
+
+func (t loginThrottle) key(id string) string {
+	return fmt.Sprintf("login:failures:%s:%s", t.kind, id)
+}
+
+// delay возвращает паузу, которую нужно выждать после count ошибок
+func (t loginThrottle) delay(count int64) time.Duration {
+	if count <= t.free {
+		return 0
+	}
+
+	shift := count - t.free - 1
+	if shift > 20 {
+		return t.maxDelay
+	}
+
+	delay := t.baseDelay << shift
+	if delay > t.maxDelay {
+		return t.maxDelay
+	}
+
+	return delay
+}
+
+type LoginAttemptModel struct {
+	Redis *redis.Client
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountLockKey(email string) string {
+	return fmt.Sprintf("login:locked:%s", email)
+}
+
+// Check возвращает, сколько нужно подождать до следующей попытки входа
+// в аккаунт email с адреса ip. locked означает, что аккаунт заблокирован.
+func (m LoginAttemptModel) Check(email, ip string) (retryAfter time.Duration, locked bool, err error) {
+	ctx := context.Background()
+	email = normalizeLoginEmail(email)
+
+	lockTTL, err := m.Redis.PTTL(ctx, accountLockKey(email)).Result()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if lockTTL > 0 {
+		return lockTTL, true, nil
+	}
+
+	for _, c := range []struct {
+		throttle loginThrottle
+		id       string
+	}{
+		{accountThrottle, email},
+		{ipThrottle, ip},
+	} {
+		wait, err := m.wait(ctx, c.throttle, c.id)
+		if err != nil {
+			return 0, false, err
+		}
+
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	return retryAfter, false, nil
+}
+
+func (m LoginAttemptModel) wait(ctx context.Context, throttle loginThrottle, id string) (time.Duration, error) {
+	values, err := m.Redis.HMGet(ctx, throttle.key(id), "count", "last").Result()
+	if err != nil {
+		return 0, err
+	}
+
+	count, _ := strconv.ParseInt(fmt.Sprint(values[0]), 10, 64)
+	last, _ := strconv.ParseInt(fmt.Sprint(values[1]), 10, 64)
+
+	wait := time.Until(time.UnixMilli(last).Add(throttle.delay(count)))
+	if wait < 0 {
+		return 0, nil
+	}
+
+	return wait, nil
+}
+
+// RecordFailure учитывает неудачную попытку. Возвращает true, если именно
+// эта попытка заблокировала аккаунт.
+func (m LoginAttemptModel) RecordFailure(email, ip string) (bool, error) {
+	ctx := context.Background()
+	email = normalizeLoginEmail(email)
+	now := time.Now().UnixMilli()
+
+	var accountCount *redis.IntCmd
+
+	_, err := m.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		accountCount = pipe.HIncrBy(ctx, accountThrottle.key(email), "count", 1)
+		pipe.HSet(ctx, accountThrottle.key(email), "last", now)
+		pipe.Expire(ctx, accountThrottle.key(email), accountThrottle.window)
+
+		pipe.HIncrBy(ctx, ipThrottle.key(ip), "count", 1)
+		pipe.HSet(ctx, ipThrottle.key(ip), "last", now)
+		pipe.Expire(ctx, ipThrottle.key(ip), ipThrottle.window)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if accountCount.Val() < AccountLockThreshold {
+		return false, nil
+	}
+
+	locked, err := m.Redis.SetNX(ctx, accountLockKey(email), 1, AccountLockDuration).Result()
+	if err != nil {
+		return false, err
+	}
+
+	// После снятия блокировки счет попыток начинается заново
+	err = m.Redis.Del(ctx, accountThrottle.key(email)).Err()
+	if err != nil {
+		return false, err
+	}
+
+	return locked, nil
+}
+
+// Reset сбрасывает счетчик аккаунта после успешного входа. Счетчик IP не
+// сбрасывается, иначе перебор можно было бы чередовать со входом в свой аккаунт.
+func (m LoginAttemptModel) Reset(email string) error {
+	return m.Redis.Del(context.Background(), accountThrottle.key(normalizeLoginEmail(email))).Err()
+}
+
+// Unlock снимает блокировку аккаунта и сбрасывает его счетчик
+func (m LoginAttemptModel) Unlock(email string) error {
+	email = normalizeLoginEmail(email)
+
+	return m.Redis.Del(context.Background(), accountLockKey(email), accountThrottle.key(email)).Err()
+}
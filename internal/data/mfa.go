@@ -0,0 +1,324 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/narxozer/graph/model"
+	"time"
+)
+
+const (
+	ScopeMFAChallenge = "mfa-challenge"
+
+	mfaRequiredRolesKey = "mfa:required_roles"
+)
+
+// TOTP — настройка второго фактора пользователя. Enabled становится true
+// после подтверждения первым кодом.
+type TOTP struct {
+	UserID          int64
+	SecretEncrypted []byte
+	Enabled         bool
+}
+
+type MFAModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// GetTOTP возвращает настройку TOTP пользователя или ErrRecordNotFound
+func (m MFAModel) GetTOTP(userID int64) (*TOTP, error) {
+	query := `
+		SELECT user_id, secret_encrypted, confirmed_at IS NOT NULL
+		FROM users_totp
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var totp TOTP
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&totp.UserID, &totp.SecretEncrypted, &totp.Enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+// SetPendingTOTP сохраняет новый неподтвержденный секрет. Включенную 2FA
+// таким образом перезаписать нельзя: возвращается ErrEditConflict.
+func (m MFAModel) SetPendingTOTP(userID int64, secretEncrypted []byte) error {
+	query := `
+		INSERT INTO users_totp (user_id, secret_encrypted)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_encrypted = EXCLUDED.secret_encrypted, created_at = NOW()
+		WHERE users_totp.confirmed_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, secretEncrypted)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+// ConfirmTOTP включает 2FA, запоминая интервал кода подтверждения, и
+// заменяет коды восстановления
+func (m MFAModel) ConfirmTOTP(userID int64, step int64, recoveryCodes []string) error {
+	query := `
+		UPDATE users_totp
+		SET confirmed_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrEditConflict
+	}
+
+	err = m.replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep отмечает интервал использованным. Возвращает false, если код
+// из этого или более позднего интервала уже предъявлялся.
+func (m MFAModel) UseTOTPStep(userID int64, step int64) (bool, error) {
+	query := `
+		UPDATE users_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// DeleteTOTP выключает 2FA и удаляет коды восстановления
+func (m MFAModel) DeleteTOTP(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes заменяет все коды восстановления пользователя новыми
+func (m MFAModel) ReplaceRecoveryCodes(userID int64, codes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.replaceRecoveryCodes(ctx, tx, userID, codes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m MFAModel) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codes []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	hashes := make([][]byte, len(codes))
+	for i, code := range codes {
+		hash := sha256.Sum256([]byte(code))
+		hashes[i] = hash[:]
+	}
+
+	query := `
+		INSERT INTO mfa_recovery_codes (user_id, code_hash)
+		SELECT $1, UNNEST($2::bytea[])
+	`
+
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(hashes))
+	return err
+}
+
+// UseRecoveryCode погашает код восстановления. Возвращает false, если кода
+// нет или он уже использован.
+func (m MFAModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	hash := sha256.Sum256([]byte(code))
+
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, hash[:])
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// GetRequiredRoles возвращает роли с обязательной 2FA. Список кешируется в Redis.
+func (m MFAModel) GetRequiredRoles() ([]model.Role, error) {
+	ctx := context.Background()
+
+	val, err := m.Redis.Get(ctx, mfaRequiredRolesKey).Result()
+	if err == nil {
+		var roles []model.Role
+		err = json.Unmarshal([]byte(val), &roles)
+		if err != nil {
+			return nil, err
+		}
+		return roles, nil
+	} else if err != redis.Nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT role FROM mfa_required_roles ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []model.Role{}
+	for rows.Next() {
+		var role model.Role
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Redis.Set(ctx, mfaRequiredRolesKey, data, 10*time.Minute).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// IsRequiredForRole сообщает, обязательна ли 2FA для роли
+func (m MFAModel) IsRequiredForRole(role model.Role) (bool, error) {
+	roles, err := m.GetRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range roles {
+		if r == role {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SetRequiredForRole включает или выключает обязательную 2FA для роли
+func (m MFAModel) SetRequiredForRole(role model.Role, required bool) error {
+	query := `DELETE FROM mfa_required_roles WHERE role = $1`
+	if required {
+		query = `INSERT INTO mfa_required_roles (role) VALUES ($1) ON CONFLICT DO NOTHING`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, role)
+	if err != nil {
+		return err
+	}
+
+	return m.Redis.Del(ctx, mfaRequiredRolesKey).Err()
+}
@@ -12,27 +12,51 @@ var (
 )
 
 type Models struct {
-	Permissions         PermissionModel
-	Users               UserModel
-	Tokens              TokenModel
-	AuthorizationTokens AuthorizationTokenModel
-	Posts               PostModel
-	Clubs               ClubModel
-	Events              EventModel
-	Topics              TopicModel
-	Comments            CommentModel
+	Permissions          PermissionModel
+	Users                UserModel
+	Blocks               BlockModel
+	Follows              FollowModel
+	Tokens               TokenModel
+	AuthorizationTokens  AuthorizationTokenModel
+	UserIdentities       UserIdentityModel
+	MFA                  MFAModel
+	PersonalAccessTokens PersonalAccessTokenModel
+	LoginAttempts        LoginAttemptModel
+	SecurityEvents       SecurityEventModel
+	Posts                PostModel
+	Clubs                ClubModel
+	Events               EventModel
+	Topics               TopicModel
+	Comments             CommentModel
+	Search               SearchModel
+	Feed                 FeedModel
+	Rankings             RankingModel
+	Uploads              UploadModel
+	DataExports          DataExportModel
 }
 
 func NewModels(db *sql.DB, redis *redis.Client) Models {
 	return Models{
-		Permissions:         PermissionModel{DB: db, Redis: redis},
-		Users:               UserModel{DB: db, Redis: redis},
-		Tokens:              TokenModel{DB: db, Redis: redis},
-		AuthorizationTokens: AuthorizationTokenModel{DB: db, Redis: redis},
-		Posts:               PostModel{DB: db, Redis: redis},
-		Clubs:               ClubModel{DB: db, Redis: redis},
-		Events:              EventModel{DB: db, Redis: redis},
-		Topics:              TopicModel{DB: db, Redis: redis},
-		Comments:            CommentModel{DB: db, Redis: redis},
+		Permissions:          PermissionModel{DB: db, Redis: redis},
+		Users:                UserModel{DB: db, Redis: redis},
+		Blocks:               BlockModel{DB: db, Redis: redis},
+		Follows:              FollowModel{DB: db, Redis: redis},
+		Tokens:               TokenModel{DB: db, Redis: redis},
+		AuthorizationTokens:  AuthorizationTokenModel{DB: db, Redis: redis},
+		UserIdentities:       UserIdentityModel{DB: db, Redis: redis},
+		MFA:                  MFAModel{DB: db, Redis: redis},
+		PersonalAccessTokens: PersonalAccessTokenModel{DB: db, Redis: redis},
+		LoginAttempts:        LoginAttemptModel{Redis: redis},
+		SecurityEvents:       SecurityEventModel{DB: db, Redis: redis},
+		Posts:                PostModel{DB: db, Redis: redis},
+		Clubs:                ClubModel{DB: db, Redis: redis},
+		Events:               EventModel{DB: db, Redis: redis},
+		Topics:               TopicModel{DB: db, Redis: redis},
+		Comments:             CommentModel{DB: db, Redis: redis},
+		Search:               SearchModel{DB: db, Redis: redis},
+		Feed:                 FeedModel{DB: db, Redis: redis},
+		Rankings:             RankingModel{DB: db, Redis: redis},
+		Uploads:              UploadModel{DB: db, Redis: redis},
+		DataExports:          DataExportModel{DB: db, Redis: redis},
 	}
 }
@@ -3,11 +3,24 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/lib/pq"
 	"time"
 )
 
+// Каталог прав. Права по умолчанию для каждой роли задаются в таблице
+// role_permissions (см. миграцию 000014).
+const (
+	PermissionPostsModerate = "posts:moderate"
+	PermissionClubsCreate   = "clubs:create"
+	PermissionUsersManage   = "users:manage"
+)
+
+var ErrUnknownPermission = errors.New("unknown permission")
+
 type Permissions []string
 
 func (p Permissions) Include(code string) bool {
@@ -25,13 +38,29 @@ type PermissionModel struct {
 	Redis *redis.Client
 }
 
+// GetAllForUser возвращает права пользователя: права его роли и выданные
+// лично, за вычетом лично отозванных
 func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	query := `
 		SELECT permissions.code
 		FROM permissions
-		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id = users_permissions.permission_id
-		INNER JOIN users ON users_permissions.user_id = users.id
-		WHERE users.id = $1
+		WHERE (
+			EXISTS (
+				SELECT 1
+				FROM role_permissions
+				INNER JOIN users ON users.role = role_permissions.role
+				WHERE users.id = $1 AND role_permissions.permission_id = permissions.id
+			)
+			OR EXISTS (
+				SELECT 1 FROM users_permissions
+				WHERE users_permissions.user_id = $1 AND users_permissions.permission_id = permissions.id AND users_permissions.granted
+			)
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM users_permissions
+			WHERE users_permissions.user_id = $1 AND users_permissions.permission_id = permissions.id AND NOT users_permissions.granted
+		)
+		ORDER BY permissions.code
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -43,12 +72,12 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	}
 	defer rows.Close()
 
-	var permissions Permissions
+	permissions := Permissions{}
 
 	for rows.Next() {
 		var permission string
 
-		err := rows.Scan(&permissions)
+		err := rows.Scan(&permission)
 		if err != nil {
 			return nil, err
 		}
@@ -62,15 +91,100 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	return permissions, nil
 }
 
+func permissionsCacheKey(userID int64) string {
+	return fmt.Sprintf("permissions:%d", userID)
+}
+
+// GetCachedForUser возвращает права пользователя из кеша Redis, загружая их
+// из базы при промахе
+func (m PermissionModel) GetCachedForUser(userID int64) (Permissions, error) {
+	ctx := context.Background()
+	cacheKey := permissionsCacheKey(userID)
+
+	val, err := m.Redis.Get(ctx, cacheKey).Result()
+	if err == nil {
+		var permissions Permissions
+		err = json.Unmarshal([]byte(val), &permissions)
+		if err != nil {
+			return nil, err
+		}
+		return permissions, nil
+	} else if err != redis.Nil {
+		return nil, err
+	}
+
+	permissions, err := m.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Redis.Set(ctx, cacheKey, data, 10*time.Minute).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// Invalidate сбрасывает кеш прав пользователя, например после смены роли
+func (m PermissionModel) Invalidate(userID int64) error {
+	return m.Redis.Del(context.Background(), permissionsCacheKey(userID)).Err()
+}
+
+// AddForUser лично выдает пользователю права
 func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	return m.setForUser(userID, true, codes)
+}
+
+// RevokeForUser лично отзывает у пользователя права, в том числе выданные ролью
+func (m PermissionModel) RevokeForUser(userID int64, codes ...string) error {
+	return m.setForUser(userID, false, codes)
+}
+
+func (m PermissionModel) setForUser(userID int64, granted bool, codes []string) error {
 	query := `
-		INSERT INTO users_permissions
-		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+		INSERT INTO users_permissions (user_id, permission_id, granted)
+		SELECT $1, permissions.id, $3 FROM permissions WHERE permissions.code = ANY($2)
+		ON CONFLICT (user_id, permission_id) DO UPDATE SET granted = EXCLUDED.granted
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
-	return err
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, userID, pq.Array(codes), granted)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// Каждому коду должна соответствовать строка каталога
+	if rows != int64(len(codes)) {
+		return ErrUnknownPermission
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return m.Invalidate(userID)
 }
@@ -0,0 +1,242 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"strings"
+	"time"
+)
+
+// PersonalAccessTokenPrefix отличает персональные токены от JWT в заголовке
+// Authorization
+const PersonalAccessTokenPrefix = "nxp_"
+
+// Области действия персональных токенов. Чтение доступно любому токену,
+// мутации — только с соответствующей областью.
+const (
+	TokenScopePostsWrite    = "posts:write"
+	TokenScopeCommentsWrite = "comments:write"
+	TokenScopeTopicsWrite   = "topics:write"
+	TokenScopeClubsWrite    = "clubs:write"
+	TokenScopeEventsWrite   = "events:write"
+	TokenScopeUploadsWrite  = "uploads:write"
+)
+
+var TokenScopes = []string{
+	TokenScopePostsWrite,
+	TokenScopeCommentsWrite,
+	TokenScopeTopicsWrite,
+	TokenScopeClubsWrite,
+	TokenScopeEventsWrite,
+	TokenScopeUploadsWrite,
+}
+
+// MaxPersonalAccessTokenDays — наибольший срок действия персонального токена
+// в днях
+const MaxPersonalAccessTokenDays = 365
+
+type PersonalAccessToken struct {
+	ID         int64
+	UserID     int64
+	UserRole   model.Role
+	Name       string
+	Scopes     []string
+	Plaintext  string
+	Hash       []byte
+	CreatedAt  time.Time
+	Expiry     time.Time
+	LastUsedAt *time.Time
+}
+
+type PersonalAccessTokenModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func ValidatePersonalAccessTokenInput(v *validator.Validator, input model.CreatePersonalAccessTokenInput) {
+	v.Check(strings.TrimSpace(input.Name) != "", "name", "must be provided")
+	v.Check(len(input.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(len(input.Scopes) > 0, "scopes", "must contain at least one scope")
+	v.Check(validator.Unique(input.Scopes), "scopes", "must not contain duplicate values")
+	for _, scope := range input.Scopes {
+		v.Check(validator.PermittedValue(scope, TokenScopes...), "scopes", "contains an unknown scope")
+	}
+
+	v.Check(input.ExpiresInDays >= 1, "expiresInDays", "must be at least 1")
+	// Сравниваем дни, а не time.Duration: большое число дней переполнило бы int64
+	v.Check(input.ExpiresInDays <= MaxPersonalAccessTokenDays, "expiresInDays", "must not be more than 365")
+}
+
+// New выпускает токен. Открытый текст доступен только в возвращенной
+// структуре, в базу попадает хеш.
+func (m PersonalAccessTokenModel) New(userID int64, name string, scopes []string, ttl time.Duration) (*PersonalAccessToken, error) {
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Plaintext: PersonalAccessTokenPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes),
+		Expiry:    time.Now().Add(ttl),
+	}
+
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{token.UserID, token.Name, token.Hash, pq.Array(token.Scopes), token.Expiry}
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Authenticate находит действующий токен по открытому тексту, отмечает время
+// использования и возвращает его вместе с ролью владельца
+func (m PersonalAccessTokenModel) Authenticate(plaintext string) (*PersonalAccessToken, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+		UPDATE personal_access_tokens t
+		SET last_used_at = NOW()
+		FROM users u
+		WHERE t.token_hash = $1 AND t.revoked_at IS NULL AND t.expiry > NOW() AND u.id = t.user_id
+		RETURNING t.id, t.user_id, u.role, t.name, t.scopes, t.created_at, t.expiry, t.last_used_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var token PersonalAccessToken
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.UserRole,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.CreatedAt,
+		&token.Expiry,
+		&token.LastUsedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// GetAllForUser возвращает действующие токены пользователя, новые первыми
+func (m PersonalAccessTokenModel) GetAllForUser(userID int64) ([]*PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, scopes, created_at, expiry, last_used_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expiry > NOW()
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*PersonalAccessToken{}
+	for rows.Next() {
+		var token PersonalAccessToken
+		err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			pq.Array(&token.Scopes),
+			&token.CreatedAt,
+			&token.Expiry,
+			&token.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke отзывает токен пользователя. Возвращает ErrRecordNotFound, если
+// действующего токена с таким id у пользователя нет.
+func (m PersonalAccessTokenModel) Revoke(userID, id int64) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllForUser отзывает все токены пользователя
+func (m PersonalAccessTokenModel) RevokeAllForUser(userID int64) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
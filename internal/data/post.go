@@ -1,10 +1,15 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 	"github.com/olzzhas/narxozer/graph/model"
+	"strconv"
+	"time"
 )
 
 type PostModel struct {
@@ -14,12 +19,12 @@ type PostModel struct {
 
 func (m PostModel) Insert(post *model.Post) (*model.Post, error) {
 	query := `
-		INSERT INTO posts (title, content, image_url, author_id, created_at)
-		VALUES ($1, $2, $3, $4, now())
+		INSERT INTO posts (title, content, image_url, author_id, club_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, now())
 		RETURNING id, created_at
 		`
 
-	args := []interface{}{post.Title, post.Content, post.ImageURL, post.Author.ID}
+	args := []interface{}{post.Title, post.Content, post.ImageURL, post.Author.ID, post.ClubID}
 
 	err := m.DB.QueryRow(query, args...).Scan(&post.ID, &post.CreatedAt)
 	if err != nil {
@@ -29,24 +34,27 @@ func (m PostModel) Insert(post *model.Post) (*model.Post, error) {
 	return post, nil
 }
 
-func (m PostModel) FindOne(id int64) (*model.Post, error) {
+// FindOne возвращает пост по ID или nil, если поста нет или автор
+// заблокировал зрителя viewerID либо заблокирован им
+func (m PostModel) FindOne(viewerID int, id int64) (*model.Post, error) {
 	query := `
-		SELECT id, title, content, image_url, author_id, created_at, updated_at, likes
+		SELECT id, title, content, image_url, author_id, club_id, created_at, updated_at, COALESCE(likes, 0), comments_count
 		FROM posts
-		WHERE id = $1
-		`
+		WHERE id = $1 AND ` + blockedCondition("author_id", 2)
 
 	var post model.Post
 	post.Author = &model.User{}
-	err := m.DB.QueryRow(query, id).Scan(
+	err := m.DB.QueryRow(query, id, viewerID).Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
 		&post.ImageURL,
 		&post.Author.ID,
+		&post.ClubID,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		&post.Likes,
+		&post.CommentsCount,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -58,13 +66,26 @@ func (m PostModel) FindOne(id int64) (*model.Post, error) {
 	return &post, nil
 }
 
-func (m PostModel) FindAll() ([]*model.Post, error) {
-	query := `
-		SELECT id, title, content, image_url, author_id, created_at, updated_at, likes
+// FindAll возвращает страницу постов без авторов, скрытых от зрителя
+// viewerID блокировкой или заглушением
+func (m PostModel) FindAll(viewerID int, sort model.RankingSort, filters CursorFilters) (*model.PostConnection, error) {
+	if sort == model.RankingSortHot {
+		return m.findHot(viewerID, filters)
+	}
+
+	column, condition := rankingOrder(sort)
+	condition += " AND " + hiddenAuthorCondition("author_id", 1)
+	where, orderBy, args := filters.keyset(column, true, 2)
+
+	query := fmt.Sprintf(`
+		SELECT id, title, content, image_url, author_id, club_id, created_at, updated_at, COALESCE(likes, 0), comments_count
 		FROM posts
-	`
+		WHERE %s AND %s
+		ORDER BY %s
+		LIMIT %d
+	`, condition, where, orderBy, filters.limit())
 
-	rows, err := m.DB.Query(query)
+	rows, err := m.DB.Query(query, append([]any{viewerID}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -80,9 +101,11 @@ func (m PostModel) FindAll() ([]*model.Post, error) {
 			&post.Content,
 			&post.ImageURL,
 			&post.Author.ID,
+			&post.ClubID,
 			&post.CreatedAt,
 			&post.UpdatedAt,
 			&post.Likes,
+			&post.CommentsCount,
 		)
 		if err != nil {
 			return nil, err
@@ -94,7 +117,93 @@ func (m PostModel) FindAll() ([]*model.Post, error) {
 		return nil, err
 	}
 
-	return posts, nil
+	var totalCount int
+	err = m.DB.QueryRow(`SELECT count(*) FROM posts WHERE `+condition, viewerID).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	cursorOf := func(post *model.Post) string {
+		return rankingCursor(column, post.ID, post.Likes, post.CommentsCount)
+	}
+
+	posts, pageInfo := paginate(filters, posts, cursorOf)
+
+	edges := make([]*model.PostEdge, 0, len(posts))
+	for _, post := range posts {
+		edges = append(edges, &model.PostEdge{Cursor: cursorOf(post), Node: post})
+	}
+
+	return &model.PostConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
+}
+
+func (m PostModel) findHot(viewerID int, filters CursorFilters) (*model.PostConnection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries, pageInfo, totalCount, err := zsetPage(ctx, m.Redis, hotPostsKey, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		id, err := strconv.Atoi(entry.Member)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	query := `
+		SELECT id, title, content, image_url, author_id, club_id, created_at, updated_at, COALESCE(likes, 0), comments_count
+		FROM posts
+		WHERE id = ANY($1) AND ` + hiddenAuthorCondition("author_id", 2)
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make(map[int]*model.Post, len(ids))
+	for rows.Next() {
+		var post model.Post
+		post.Author = &model.User{}
+		err := rows.Scan(
+			&post.ID,
+			&post.Title,
+			&post.Content,
+			&post.ImageURL,
+			&post.Author.ID,
+			&post.ClubID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Likes,
+			&post.CommentsCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts[post.ID] = &post
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	edges := make([]*model.PostEdge, 0, len(entries))
+	for i, entry := range entries {
+		// Пост мог быть удалён после последнего пересчёта рейтинга или
+		// скрыт от зрителя
+		post, ok := posts[ids[i]]
+		if !ok {
+			continue
+		}
+		edges = append(edges, &model.PostEdge{Cursor: entry.Cursor, Node: post})
+	}
+
+	return &model.PostConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
 }
 
 func (m PostModel) Update(post *model.Post) error {
@@ -113,6 +222,13 @@ func (m PostModel) Update(post *model.Post) error {
 	return nil
 }
 
+// ToggleLike ставит или снимает лайк пользователя на посте. Возвращает
+// ErrRecordNotFound или ErrBlocked, если поста нет или автор с
+// пользователем заблокировали друг друга.
+func (m PostModel) ToggleLike(userID int, id int64) error {
+	return toggleLike(m.DB, "posts", "post", userID, int(id))
+}
+
 func (m PostModel) Delete(id int64) error {
 	query := `
 		DELETE FROM posts
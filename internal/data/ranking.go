@@ -0,0 +1,230 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/olzzhas/narxozer/graph/model"
+	"strconv"
+	"time"
+)
+
+const (
+	hotTopicsKey = "ranking:topics:hot"
+	hotPostsKey  = "ranking:posts:hot"
+)
+
+type RankingModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// Refresh пересчитывает hot-рейтинг топиков и постов за последние 30 дней.
+// Score = (лайки + 2 * комментарии) / (возраст в часах + 2)^1.5, так что
+// свежие обсуждения поднимаются выше старых с тем же числом реакций.
+func (m RankingModel) Refresh() error {
+	err := m.refresh("topics", hotTopicsKey)
+	if err != nil {
+		return err
+	}
+
+	return m.refresh("posts", hotPostsKey)
+}
+
+func (m RankingModel) refresh(table, key string) error {
+	query := fmt.Sprintf(`
+		SELECT id, (COALESCE(likes, 0) + 2 * comments_count)::float8 / power(extract(epoch FROM now() - created_at) / 3600 + 2, 1.5)
+		FROM %s
+		WHERE created_at > now() - INTERVAL '30 days'`, table)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var members []*redis.Z
+	for rows.Next() {
+		var (
+			id    int
+			score float64
+		)
+		err := rows.Scan(&id, &score)
+		if err != nil {
+			return err
+		}
+		members = append(members, &redis.Z{Score: score, Member: strconv.Itoa(id)})
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(members) == 0 {
+		return m.Redis.Del(ctx, key).Err()
+	}
+
+	// Собираем новый рейтинг во временном ключе и атомарно подменяем старый,
+	// чтобы читатели не увидели наполовину заполненный set.
+	tmpKey := key + ":tmp"
+
+	pipe := m.Redis.TxPipeline()
+	pipe.Del(ctx, tmpKey)
+	pipe.ZAdd(ctx, tmpKey, members...)
+	pipe.Rename(ctx, tmpKey, key)
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+// hotTopicIDs возвращает id n самых горячих топиков.
+func hotTopicIDs(ctx context.Context, rdb *redis.Client, n int) ([]int, error) {
+	members, err := rdb.ZRevRange(ctx, hotTopicsKey, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+type zsetEntry struct {
+	Member string
+	Score  float64
+	Cursor string
+}
+
+// zsetPage читает страницу sorted set по убыванию score, начиная после
+// курсора. Курсор хранит score и member последнего элемента, поэтому
+// пересчёт рейтинга между запросами не приводит к повторам на страницах.
+func zsetPage(ctx context.Context, rdb *redis.Client, key string, filters CursorFilters) ([]*zsetEntry, *model.PageInfo, int, error) {
+	totalCount, err := rdb.ZCard(ctx, key).Result()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	var offset int64
+	if c := filters.cursor(); c != nil {
+		offset, err = zsetOffset(ctx, rdb, key, c)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	values, err := rdb.ZRevRangeWithScores(ctx, key, offset, offset+int64(filters.limit())-1).Result()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	entries := make([]*zsetEntry, 0, len(values))
+	for _, value := range values {
+		member, _ := value.Member.(string)
+		entries = append(entries, &zsetEntry{
+			Member: member,
+			Score:  value.Score,
+			Cursor: EncodeScoreCursor(value.Score, member),
+		})
+	}
+
+	entries, pageInfo := paginate(filters, entries, func(entry *zsetEntry) string {
+		return entry.Cursor
+	})
+
+	return entries, pageInfo, int(totalCount), nil
+}
+
+// zsetOffset возвращает позицию первого элемента после курсора. Элементы с
+// равным score Redis упорядочивает по member, поэтому позиция однозначна
+// даже когда у многих элементов одинаковый score (например, нулевой hot).
+func zsetOffset(ctx context.Context, rdb *redis.Client, key string, c *cursor) (int64, error) {
+	score := strconv.FormatFloat(c.Score, 'g', -1, 64)
+
+	// Пока score элемента под курсором не изменился, продолжаем с его ранга
+	current, err := rdb.ZScore(ctx, key, c.Value).Result()
+	switch {
+	case err == nil && current == c.Score:
+		rank, err := rdb.ZRevRank(ctx, key, c.Value).Result()
+		if err == nil {
+			return rank + 1, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+	case err != nil && !errors.Is(err, redis.Nil):
+		return 0, err
+	}
+
+	// Элемент удалён или пересчитан: пропускаем всё, что выше его score,
+	// и те элементы с тем же score, что шли до него.
+	above, err := rdb.ZCount(ctx, key, "("+score, "+inf").Result()
+	if err != nil {
+		return 0, err
+	}
+
+	const batch = 100
+
+	var seen int64
+	for {
+		members, err := rdb.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min:    score,
+			Max:    score,
+			Offset: seen,
+			Count:  batch,
+		}).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		for _, member := range members {
+			if member < c.Value {
+				return above + seen, nil
+			}
+			seen++
+		}
+
+		if len(members) < batch {
+			return above + seen, nil
+		}
+	}
+}
+
+// likesColumn — выражение сортировки по лайкам; у старых записей likes
+// может быть NULL
+const likesColumn = "COALESCE(likes, 0)"
+
+// rankingOrder возвращает колонку сортировки и дополнительное условие
+// выборки для сортировок, которые считаются прямо в PostgreSQL.
+func rankingOrder(sort model.RankingSort) (string, string) {
+	switch sort {
+	case model.RankingSortTopWeek:
+		return likesColumn, "created_at > now() - INTERVAL '7 days'"
+	case model.RankingSortMostCommented:
+		return "comments_count", "TRUE"
+	default:
+		return "id", "TRUE"
+	}
+}
+
+func rankingCursor(column string, id, likes, commentsCount int) string {
+	switch column {
+	case likesColumn:
+		return EncodeCursor(strconv.Itoa(likes), id)
+	case "comments_count":
+		return EncodeCursor(strconv.Itoa(commentsCount), id)
+	default:
+		return EncodeCursor("", id)
+	}
+}
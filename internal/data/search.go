@@ -0,0 +1,122 @@
+package data
+
+import (
+	"database/sql"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/narxozer/graph/model"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	SearchTypePost  = "post"
+	SearchTypeTopic = "topic"
+	SearchTypeClub  = "club"
+	SearchTypeEvent = "event"
+	SearchTypeUser  = "user"
+)
+
+var AllSearchTypes = []string{SearchTypePost, SearchTypeTopic, SearchTypeClub, SearchTypeEvent, SearchTypeUser}
+
+type SearchHit struct {
+	Type    string
+	ID      int
+	Rank    float64
+	Snippet string
+	Cursor  string
+}
+
+type SearchModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func ValidateSearchQuery(v *validator.Validator, query string) {
+	v.Check(strings.TrimSpace(query) != "", "query", "must be provided")
+	v.Check(utf8.RuneCountInString(query) <= 200, "query", "must not be more than 200 characters long")
+}
+
+// Search ищет по постам, топикам, клубам, событиям и пользователям и
+// возвращает совпадения по убыванию релевантности. Так как порядок задаёт
+// ранг, а не id, курсор здесь хранит позицию записи в выдаче. Посты, топики
+// и пользователи, скрытые от зрителя viewerID, в выдачу не попадают.
+func (m SearchModel) Search(viewerID int, query string, types []string, filters CursorFilters) ([]*SearchHit, *model.PageInfo, int, error) {
+	if len(types) == 0 {
+		types = AllSearchTypes
+	}
+
+	offset := 0
+	if c := filters.cursor(); c != nil {
+		offset = c.ID
+	}
+
+	stmt := `
+		WITH q AS (
+			SELECT multilingual_tsquery($1) AS query
+		),
+		hits AS (
+			SELECT 'post' AS entity_type, p.id, ts_rank(p.search_vector, q.query) AS rank, p.title || ' ' || p.content AS document
+			FROM posts p, q
+			WHERE 'post' = ANY($2) AND p.search_vector @@ q.query
+			AND ` + hiddenAuthorCondition("p.author_id", 5) + `
+			UNION ALL
+			SELECT 'topic', t.id, ts_rank(t.search_vector, q.query), t.title || ' ' || t.content
+			FROM topics t, q
+			WHERE 'topic' = ANY($2) AND t.search_vector @@ q.query
+			AND ` + hiddenAuthorCondition("t.author_id", 5) + `
+			UNION ALL
+			SELECT 'club', c.id, ts_rank(c.search_vector, q.query), c.name || ' ' || c.description
+			FROM clubs c, q
+			WHERE 'club' = ANY($2) AND c.search_vector @@ q.query
+			UNION ALL
+			SELECT 'event', e.id, ts_rank(e.search_vector, q.query), e.title || ' ' || e.description
+			FROM events e, q
+			WHERE 'event' = ANY($2) AND e.search_vector @@ q.query
+			UNION ALL
+			SELECT 'user', u.id, ts_rank(u.search_vector, q.query), u.name || ' ' || u.lastname
+			FROM users u, q
+			WHERE 'user' = ANY($2) AND u.search_vector @@ q.query AND u.deleted_at IS NULL
+			AND ` + blockedCondition("u.id", 5) + `
+		),
+		page AS (
+			SELECT entity_type, id, rank, document, count(*) OVER() AS total
+			FROM hits
+			ORDER BY rank DESC, entity_type, id
+			LIMIT $3 OFFSET $4
+		)
+		SELECT page.entity_type, page.id, page.rank,
+			ts_headline('russian', page.document, q.query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2'),
+			page.total
+		FROM page, q
+		ORDER BY page.rank DESC, page.entity_type, page.id`
+
+	rows, err := m.DB.Query(stmt, query, pq.Array(types), filters.limit(), offset, viewerID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer rows.Close()
+
+	var hits []*SearchHit
+	totalCount := 0
+	for rows.Next() {
+		var hit SearchHit
+		err := rows.Scan(&hit.Type, &hit.ID, &hit.Rank, &hit.Snippet, &totalCount)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		hit.Cursor = EncodeCursor("", offset+len(hits)+1)
+		hits = append(hits, &hit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, 0, err
+	}
+
+	hits, pageInfo := paginate(filters, hits, func(hit *SearchHit) string {
+		return hit.Cursor
+	})
+
+	return hits, pageInfo, totalCount, nil
+}
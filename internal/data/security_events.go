@@ -0,0 +1,88 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-redis/redis/v8"
+	"github.com/olzzhas/narxozer/graph/model"
+	"time"
+)
+
+// Типы событий журнала безопасности
+const (
+	SecurityEventLoginSucceeded  = "login_succeeded"
+	SecurityEventLoginFailed     = "login_failed"
+	SecurityEventLoginThrottled  = "login_throttled"
+	SecurityEventMFAFailed       = "mfa_failed"
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+)
+
+type SecurityEvent struct {
+	UserID    *int64
+	Email     string
+	Type      string
+	IPAddress string
+	UserAgent string
+}
+
+type SecurityEventModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func (m SecurityEventModel) Insert(event SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (user_id, email, event_type, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, event.UserID, event.Email, event.Type, event.IPAddress, event.UserAgent)
+	return err
+}
+
+// GetAllForUser возвращает последние события пользователя, новые первыми
+func (m SecurityEventModel) GetAllForUser(userID int64, limit int) ([]*model.SecurityEvent, error) {
+	query := `
+		SELECT id, event_type, email, ip_address, user_agent, created_at
+		FROM security_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*model.SecurityEvent{}
+	for rows.Next() {
+		var event model.SecurityEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.Email,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
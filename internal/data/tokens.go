@@ -8,13 +8,16 @@ import (
 	"encoding/base32"
 	"errors"
 	"github.com/go-redis/redis/v8"
+	"github.com/olzzhas/narxozer/graph/model"
 	"github.com/olzzhas/narxozer/internal/validator"
 	"time"
 )
 
 const (
-	ScopeActivation     = "activation"
-	ScopeAuthentication = "authentication"
+	ScopeActivation      = "activation"
+	ScopeAuthentication  = "authentication"
+	ScopePasswordReset   = "password-reset"
+	ScopeAccountDeletion = "account-deletion"
 )
 
 type Token struct {
@@ -99,43 +102,53 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	return err
 }
 
-func (m UserModel) GetForToken(tokenPlaintext string) (*User, error) {
+// GetForToken возвращает владельца действующего токена с указанной областью
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*model.User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
-	// TODO expiry
 	query := `
-		SELECT users.id, users.created_at, users.name, users.surname, users.email, users.password_hash, users.activated, users.version
+		SELECT users.id, users.email, users.name, users.lastname, users.role, users.image_url, users.additional_information,
+		       users.course, users.major, users.degree, users.faculty, users.activated,
+		       users.follow_approval_required, users.followers_count, users.following_count, users.created_at, users.updated_at,
+		       users.email_visibility, users.course_visibility, users.major_visibility, users.faculty_visibility, users.additional_information_visibility
 		FROM users
-		INNER JOIN authorization_tokens
-		ON users.id = authorization_tokens.user_id
-		WHERE authorization_tokens.refresh_token = $1
-		AND authorization_tokens.created_at + INTERVAL '30 day' > $2
+		INNER JOIN tokens
+		ON users.id = tokens.user_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3
 	`
 
-	//query := `
-	//	SELECT users.id, users.created_at, users.name, users.surname, users.email, users.password_hash, users.activated, users.version
-	//	FROM users
-	//	INNER JOIN tokens
-	//	ON users.id = tokens.user_id
-	//	WHERE tokens.hash = $1
-	//	AND tokens.scope = $2
-	//	AND tokens.expiry > $3
-	//`
-
-	args := []any{tokenHash[:], time.Now()}
-	var user User
+	args := []any{tokenHash[:], tokenScope, time.Now()}
+
+	var user model.User
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
 		&user.ID,
-		&user.CreatedAt,
-		&user.Name,
-		&user.Surname,
 		&user.Email,
-		&user.Password.hash,
+		&user.Name,
+		&user.Lastname,
+		&user.Role,
+		&user.ImageURL,
+		&user.AdditionalInformation,
+		&user.Course,
+		&user.Major,
+		&user.Degree,
+		&user.Faculty,
 		&user.Activated,
-		&user.Version,
+		&user.RequiresFollowApproval,
+		&user.FollowersCount,
+		&user.FollowingCount,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Privacy.Email,
+		&user.Privacy.Course,
+		&user.Privacy.Major,
+		&user.Privacy.Faculty,
+		&user.Privacy.AdditionalInformation,
 	)
 	if err != nil {
 		switch {
@@ -1,10 +1,15 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 	"github.com/olzzhas/narxozer/graph/model"
+	"strconv"
+	"time"
 )
 
 type TopicModel struct {
@@ -27,10 +32,25 @@ func (m TopicModel) Insert(topic *model.Topic) (*model.Topic, error) {
 	return topic, nil
 }
 
-// GetAll возвращает все топики из базы данных
-func (m TopicModel) GetAll() ([]*model.Topic, error) {
-	query := `SELECT id, title, content, image_url, author_id, created_at, updated_at FROM topics`
-	rows, err := m.DB.Query(query)
+// GetAll возвращает страницу топиков в заданном порядке без авторов, скрытых
+// от зрителя viewerID блокировкой или заглушением
+func (m TopicModel) GetAll(viewerID int, sort model.RankingSort, filters CursorFilters) (*model.TopicConnection, error) {
+	if sort == model.RankingSortHot {
+		return m.getHot(viewerID, filters)
+	}
+
+	column, condition := rankingOrder(sort)
+	condition += " AND " + hiddenAuthorCondition("author_id", 1)
+	where, orderBy, args := filters.keyset(column, true, 2)
+
+	query := fmt.Sprintf(`
+		SELECT id, title, content, image_url, author_id, created_at, updated_at, COALESCE(likes, 0), comments_count
+		FROM topics
+		WHERE %s AND %s
+		ORDER BY %s
+		LIMIT %d`, condition, where, orderBy, filters.limit())
+
+	rows, err := m.DB.Query(query, append([]any{viewerID}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -40,25 +60,106 @@ func (m TopicModel) GetAll() ([]*model.Topic, error) {
 	for rows.Next() {
 		var topic model.Topic
 		topic.Author = &model.User{}
-		err := rows.Scan(&topic.ID, &topic.Title, &topic.Content, &topic.ImageURL, &topic.Author.ID, &topic.CreatedAt, &topic.UpdatedAt)
+		err := rows.Scan(&topic.ID, &topic.Title, &topic.Content, &topic.ImageURL, &topic.Author.ID, &topic.CreatedAt, &topic.UpdatedAt, &topic.Likes, &topic.CommentsCount)
 		if err != nil {
 			return nil, err
 		}
 		topics = append(topics, &topic)
 	}
 
-	return topics, nil
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var totalCount int
+	err = m.DB.QueryRow(`SELECT count(*) FROM topics WHERE `+condition, viewerID).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	cursorOf := func(topic *model.Topic) string {
+		return rankingCursor(column, topic.ID, topic.Likes, topic.CommentsCount)
+	}
+
+	topics, pageInfo := paginate(filters, topics, cursorOf)
+
+	edges := make([]*model.TopicEdge, 0, len(topics))
+	for _, topic := range topics {
+		edges = append(edges, &model.TopicEdge{Cursor: cursorOf(topic), Node: topic})
+	}
+
+	return &model.TopicConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
 }
 
-// GetByID возвращает топик по его ID
-func (m TopicModel) GetByID(id int) (*model.Topic, error) {
+// getHot возвращает страницу топиков по hot-рейтингу из Redis
+func (m TopicModel) getHot(viewerID int, filters CursorFilters) (*model.TopicConnection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries, pageInfo, totalCount, err := zsetPage(ctx, m.Redis, hotTopicsKey, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		id, err := strconv.Atoi(entry.Member)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
 	query := `
-		SELECT id, title, content, image_url, author_id, created_at, updated_at, likes
+		SELECT id, title, content, image_url, author_id, created_at, updated_at, COALESCE(likes, 0), comments_count
+		FROM topics
+		WHERE id = ANY($1) AND ` + hiddenAuthorCondition("author_id", 2)
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := make(map[int]*model.Topic, len(ids))
+	for rows.Next() {
+		var topic model.Topic
+		topic.Author = &model.User{}
+		err := rows.Scan(&topic.ID, &topic.Title, &topic.Content, &topic.ImageURL, &topic.Author.ID, &topic.CreatedAt, &topic.UpdatedAt, &topic.Likes, &topic.CommentsCount)
+		if err != nil {
+			return nil, err
+		}
+		topics[topic.ID] = &topic
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	edges := make([]*model.TopicEdge, 0, len(entries))
+	for i, entry := range entries {
+		// Топик мог быть удалён после последнего пересчёта рейтинга или
+		// скрыт от зрителя
+		topic, ok := topics[ids[i]]
+		if !ok {
+			continue
+		}
+		edges = append(edges, &model.TopicEdge{Cursor: entry.Cursor, Node: topic})
+	}
+
+	return &model.TopicConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
+}
+
+// GetByID возвращает топик по его ID или nil, если топика нет или его автор
+// и зритель viewerID заблокировали друг друга
+func (m TopicModel) GetByID(viewerID, id int) (*model.Topic, error) {
+	query := `
+		SELECT id, title, content, image_url, author_id, created_at, updated_at, COALESCE(likes, 0), comments_count
 		FROM topics 
-		WHERE id = $1`
+		WHERE id = $1 AND ` + blockedCondition("author_id", 2)
 	topic := &model.Topic{}
 	topic.Author = &model.User{}
-	err := m.DB.QueryRow(query, id).Scan(
+	err := m.DB.QueryRow(query, id, viewerID).Scan(
 		&topic.ID,
 		&topic.Title,
 		&topic.Content,
@@ -67,6 +168,7 @@ func (m TopicModel) GetByID(id int) (*model.Topic, error) {
 		&topic.CreatedAt,
 		&topic.UpdatedAt,
 		&topic.Likes, // Добавлено поле likes
+		&topic.CommentsCount,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
@@ -95,6 +197,12 @@ func (m TopicModel) Update(topic *model.Topic) (*model.Topic, error) {
 	return topic, nil
 }
 
+// ToggleLike ставит или снимает лайк пользователя на топике. Возвращает
+// ErrRecordNotFound или ErrBlocked, как и PostModel.ToggleLike.
+func (m TopicModel) ToggleLike(userID, id int) error {
+	return toggleLike(m.DB, "topics", "topic", userID, id)
+}
+
 // Delete удаляет топик по его ID
 func (m TopicModel) Delete(id int) error {
 	query := `DELETE FROM topics WHERE id = $1`
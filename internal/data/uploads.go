@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/olzzhas/narxozer/internal/image"
+	"github.com/olzzhas/narxozer/internal/validator"
+	"time"
+)
+
+// Назначение загрузки: к какой сущности ее можно прикрепить
+const (
+	UploadKindPost    = "post"
+	UploadKindTopic   = "topic"
+	UploadKindClub    = "club"
+	UploadKindEvent   = "event"
+	UploadKindComment = "comment"
+	UploadKindAvatar  = "avatar"
+)
+
+// UploadTTL — сколько неподтвержденная загрузка ждет файл. Необработанные
+// файлы под incoming/ стоит удалять правилом жизненного цикла бакета.
+const UploadTTL = time.Hour
+
+var uploadContentTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+type Upload struct {
+	ID          string
+	UserID      int64
+	Kind        string
+	ContentType string
+	Size        int64
+	ImageKey    *string
+	CreatedAt   time.Time
+}
+
+// ObjectKey — ключ, по которому клиент загружает исходный файл
+func (u *Upload) ObjectKey() string {
+	return fmt.Sprintf("incoming/%d/%s", u.UserID, u.ID)
+}
+
+type UploadModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func ValidateUpload(v *validator.Validator, contentType string, size int64) {
+	v.Check(validator.PermittedValue(contentType, uploadContentTypes...), "contentType", "must be image/jpeg, image/png or image/webp")
+	v.Check(size > 0, "size", "must be greater than zero")
+	v.Check(size <= image.MaxFileSize, "size", fmt.Sprintf("must not be more than %d bytes", image.MaxFileSize))
+}
+
+// New регистрирует ожидаемую загрузку со случайным id
+func (m UploadModel) New(userID int64, kind, contentType string, size int64) (*Upload, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &Upload{
+		ID:          hex.EncodeToString(randomBytes),
+		UserID:      userID,
+		Kind:        kind,
+		ContentType: contentType,
+		Size:        size,
+	}
+
+	query := `
+		INSERT INTO uploads (id, user_id, kind, content_type, size)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, upload.ID, upload.UserID, upload.Kind, upload.ContentType, upload.Size).Scan(&upload.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// GetPending возвращает неподтвержденную и не просроченную загрузку
+// пользователя или ErrRecordNotFound
+func (m UploadModel) GetPending(id string, userID int64) (*Upload, error) {
+	query := `
+		SELECT id, user_id, kind, content_type, size, created_at
+		FROM uploads
+		WHERE id = $1 AND user_id = $2 AND status = 'pending' AND created_at > $3
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var upload Upload
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID, time.Now().Add(-UploadTTL)).Scan(
+		&upload.ID,
+		&upload.UserID,
+		&upload.Kind,
+		&upload.ContentType,
+		&upload.Size,
+		&upload.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &upload, nil
+}
+
+// Confirm отмечает загрузку проверенной и запоминает ключ обработанного
+// изображения. Возвращает ErrRecordNotFound, если загрузка уже подтверждена.
+func (m UploadModel) Confirm(id string, userID int64, imageKey string) error {
+	query := `
+		UPDATE uploads
+		SET status = 'confirmed', image_key = $3, confirmed_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status = 'pending'
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID, imageKey)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Attach возвращает ключ изображения подтвержденной загрузки пользователя
+// нужного назначения и отмечает, что она используется. Возвращает
+// ErrRecordNotFound для чужих, неподтвержденных и несуществующих загрузок.
+func (m UploadModel) Attach(id string, userID int64, kind string) (string, error) {
+	query := `
+		UPDATE uploads
+		SET attached_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND kind = $3 AND status = 'confirmed'
+		RETURNING image_key
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var imageKey string
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID, kind).Scan(&imageKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return imageKey, nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// OIDCRequest — параметры начатого входа через внешний провайдер, которые
+// нужно сверить при возврате пользователя на callback
+type OIDCRequest struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type UserIdentityModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// GetUserID возвращает пользователя, к которому привязана учетная запись
+// провайдера, и отмечает время входа
+func (m UserIdentityModel) GetUserID(issuer, subject string) (int, error) {
+	query := `
+		UPDATE user_identities
+		SET last_login_at = NOW()
+		WHERE issuer = $1 AND subject = $2
+		RETURNING user_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int
+
+	err := m.DB.QueryRowContext(ctx, query, issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Link привязывает учетную запись провайдера к пользователю
+func (m UserIdentityModel) Link(userID int, issuer, subject, email string) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, issuer, subject, email)
+	return err
+}
+
+func oidcRequestKey(state string) string {
+	return fmt.Sprintf("oidc:state:%s", state)
+}
+
+// SaveRequest запоминает параметры входа до возврата пользователя от провайдера
+func (m UserIdentityModel) SaveRequest(state string, request OIDCRequest, ttl time.Duration) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	return m.Redis.Set(context.Background(), oidcRequestKey(state), data, ttl).Err()
+}
+
+// TakeRequest возвращает и удаляет параметры входа, так что каждый state
+// можно использовать только один раз
+func (m UserIdentityModel) TakeRequest(state string) (*OIDCRequest, error) {
+	val, err := m.Redis.GetDel(context.Background(), oidcRequestKey(state)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	var request OIDCRequest
+	err = json.Unmarshal([]byte(val), &request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
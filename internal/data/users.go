@@ -7,9 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 	"github.com/olzzhas/narxozer/graph/model"
 	"github.com/olzzhas/narxozer/internal/validator"
 	"golang.org/x/crypto/bcrypt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -43,6 +46,8 @@ var (
 	ErrDuplicateEmail = errors.New("duplicate email")
 )
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type UserModel struct {
 	DB    *sql.DB
 	Redis *redis.Client
@@ -105,11 +110,30 @@ func ValidateUser(v *validator.Validator, user *User) {
 	}
 }
 
+func ValidateRegisterInput(v *validator.Validator, input model.RegisterInput) {
+	v.Check(input.Name != "", "name", "must be provided")
+	v.Check(len(input.Name) <= 255, "name", "must not be more than 255 bytes long")
+
+	v.Check(input.Lastname != "", "lastname", "must be provided")
+	v.Check(len(input.Lastname) <= 255, "lastname", "must not be more than 255 bytes long")
+
+	ValidateEmail(v, input.Email)
+	ValidatePasswordPlaintext(v, input.Password)
+
+	if input.Course != nil {
+		v.Check(*input.Course >= 1 && *input.Course <= 6, "course", "must be between 1 and 6")
+	}
+
+	// Загрузка файлов требует аккаунта, поэтому аватар ставится после регистрации
+	v.Check(input.ImageURL == nil, "imageURL", "must be set after registration with updateProfileImage")
+}
+
 func (m UserModel) Insert(user *model.User) error {
 	query := `
 		INSERT INTO users (email, name, lastname, password_hash, role, image_url, additional_information, course, major, degree, faculty)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, created_at, updated_at`
+		RETURNING id, created_at, updated_at,
+		          email_visibility, course_visibility, major_visibility, faculty_visibility, additional_information_visibility`
 
 	args := []interface{}{
 		user.Email,
@@ -125,29 +149,105 @@ func (m UserModel) Insert(user *model.User) error {
 		user.Faculty,
 	}
 
-	err := m.DB.QueryRow(query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := m.DB.QueryRow(query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Privacy.Email,
+		&user.Privacy.Course,
+		&user.Privacy.Major,
+		&user.Privacy.Faculty,
+		&user.Privacy.AdditionalInformation,
+	)
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_key" {
+			return ErrDuplicateEmail
+		}
 		return err
 	}
 
 	return nil
 }
 
-func (m UserModel) GetAll() ([]*model.User, error) {
-	query := `
-		SELECT id, email, name, lastname, role, image_url, additional_information, course, major, degree, faculty, created_at, updated_at
+var UserSortSafelist = []string{
+	"id", "name", "lastname", "course", "created_at",
+	"-id", "-name", "-lastname", "-course", "-created_at",
+}
+
+// userSortCursor возвращает значение колонки сортировки, которое
+// сохраняется в курсоре вместе с id пользователя. Для курса это значение
+// с учетом приватности, посчитанное в запросе.
+func userSortCursor(column string, user *model.User, courseKey int) string {
+	switch column {
+	case "name":
+		return user.Name
+	case "lastname":
+		return user.Lastname
+	case "course":
+		return strconv.Itoa(courseKey)
+	case "created_at":
+		return user.CreatedAt
+	default:
+		return ""
+	}
+}
+
+// GetAll возвращает страницу каталога пользователей. Пользователи, связанные
+// со зрителем viewerID блокировкой, в каталог не попадают.
+func (m UserModel) GetAll(viewerID int, filter model.UserFilter, filters Filters, page CursorFilters) (*model.UserConnection, error) {
+	column := filters.sortColumn()
+
+	// course может быть NULL, а сравнение кортежей с NULL ломает keyset.
+	// Скрытый от зрителя курс сортируется как отсутствующий, иначе порядок
+	// выдавал бы его.
+	sortExpr := column
+	courseKey := "0"
+	if column == "course" {
+		sortExpr = "CASE WHEN " + visibleCondition("course_visibility", 7) + " THEN COALESCE(course, 0) ELSE 0 END"
+		courseKey = sortExpr
+	}
+
+	var nameContains *string
+	if filter.NameContains != nil && *filter.NameContains != "" {
+		pattern := "%" + likeEscaper.Replace(*filter.NameContains) + "%"
+		nameContains = &pattern
+	}
+
+	conditions := `
+		($1::text IS NULL OR (faculty = $1 AND ` + visibleCondition("faculty_visibility", 7) + `))
+		AND ($2::text IS NULL OR (major = $2 AND ` + visibleCondition("major_visibility", 7) + `))
+		AND ($3::int IS NULL OR (course = $3 AND ` + visibleCondition("course_visibility", 7) + `))
+		AND ($4::text IS NULL OR degree = $4)
+		AND ($5::text IS NULL OR role = $5)
+		AND ($6::text IS NULL OR (name || ' ' || lastname) ILIKE $6)
+		AND deleted_at IS NULL
+		AND ` + blockedCondition("id", 7)
+
+	args := []any{filter.Faculty, filter.Major, filter.Course, filter.Degree, filter.Role, nameContains, viewerID}
+
+	where, orderBy, keysetArgs := page.keyset(sortExpr, filters.sortDirection() == "DESC", len(args)+1)
+
+	query := fmt.Sprintf(`
+		SELECT id, email, name, lastname, role, image_url, additional_information, course, major, degree, faculty, activated, follow_approval_required, followers_count, following_count, created_at, updated_at,
+		       email_visibility, course_visibility, major_visibility, faculty_visibility, additional_information_visibility, %s
 		FROM users
-	`
+		WHERE %s AND %s
+		ORDER BY %s
+		LIMIT %d
+	`, courseKey, conditions, where, orderBy, page.limit())
 
-	rows, err := m.DB.Query(query)
+	rows, err := m.DB.Query(query, append(args, keysetArgs...)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var users []*model.User
+	courseKeys := make(map[int]int)
 	for rows.Next() {
 		var user model.User
+		var courseKey int
 		err := rows.Scan(
 			&user.ID,
 			&user.Email,
@@ -160,20 +260,48 @@ func (m UserModel) GetAll() ([]*model.User, error) {
 			&user.Major,
 			&user.Degree,
 			&user.Faculty,
+			&user.Activated,
+			&user.RequiresFollowApproval,
+			&user.FollowersCount,
+			&user.FollowingCount,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&user.Privacy.Email,
+			&user.Privacy.Course,
+			&user.Privacy.Major,
+			&user.Privacy.Faculty,
+			&user.Privacy.AdditionalInformation,
+			&courseKey,
 		)
 		if err != nil {
 			return nil, err
 		}
 		users = append(users, &user)
+		courseKeys[user.ID] = courseKey
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	var totalCount int
+	err = m.DB.QueryRow(`SELECT count(*) FROM users WHERE `+conditions, args...).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	cursorOf := func(user *model.User) string {
+		return EncodeCursor(userSortCursor(column, user, courseKeys[user.ID]), user.ID)
+	}
+
+	users, pageInfo := paginate(page, users, cursorOf)
+
+	edges := make([]*model.UserEdge, 0, len(users))
+	for _, user := range users {
+		edges = append(edges, &model.UserEdge{Cursor: cursorOf(user), Node: user})
+	}
+
+	return &model.UserConnection{Edges: edges, PageInfo: pageInfo, TotalCount: totalCount}, nil
 }
 
 func (m UserModel) GetCached(userID int) (*model.User, error) {
@@ -215,7 +343,8 @@ func (m UserModel) GetCached(userID int) (*model.User, error) {
 
 func (m UserModel) Get(id int) (*model.User, error) {
 	query := `
-		SELECT id, email, name, lastname, role, image_url, additional_information, course, major, degree, faculty, created_at, updated_at
+		SELECT id, email, name, lastname, role, image_url, additional_information, course, major, degree, faculty, activated, follow_approval_required, followers_count, following_count, created_at, updated_at,
+		       email_visibility, course_visibility, major_visibility, faculty_visibility, additional_information_visibility
 		FROM users
 		WHERE id = $1
 	`
@@ -233,8 +362,17 @@ func (m UserModel) Get(id int) (*model.User, error) {
 		&user.Major,
 		&user.Degree,
 		&user.Faculty,
+		&user.Activated,
+		&user.RequiresFollowApproval,
+		&user.FollowersCount,
+		&user.FollowingCount,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Privacy.Email,
+		&user.Privacy.Course,
+		&user.Privacy.Major,
+		&user.Privacy.Faculty,
+		&user.Privacy.AdditionalInformation,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -248,7 +386,8 @@ func (m UserModel) Get(id int) (*model.User, error) {
 
 func (m UserModel) GetByEmail(email string) (*model.User, error) {
 	query := `
-		SELECT id, email, name, lastname, password_hash, role, image_url, additional_information, course, major, degree, faculty, created_at, updated_at
+		SELECT id, email, name, lastname, password_hash, role, image_url, additional_information, course, major, degree, faculty, activated, follow_approval_required, followers_count, following_count, created_at, updated_at,
+		       email_visibility, course_visibility, major_visibility, faculty_visibility, additional_information_visibility
 		FROM users
 		WHERE email = $1`
 
@@ -267,8 +406,17 @@ func (m UserModel) GetByEmail(email string) (*model.User, error) {
 		&user.Major,
 		&user.Degree,
 		&user.Faculty,
+		&user.Activated,
+		&user.RequiresFollowApproval,
+		&user.FollowersCount,
+		&user.FollowingCount,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Privacy.Email,
+		&user.Privacy.Course,
+		&user.Privacy.Major,
+		&user.Privacy.Faculty,
+		&user.Privacy.AdditionalInformation,
 	)
 
 	if err != nil {
@@ -281,6 +429,55 @@ func (m UserModel) GetByEmail(email string) (*model.User, error) {
 	return &user, nil
 }
 
+// Activate отмечает email пользователя подтвержденным и сбрасывает его кеш
+func (m UserModel) Activate(id int) error {
+	query := `
+		UPDATE users
+		SET activated = true, updated_at = now()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return m.Redis.Del(ctx, fmt.Sprintf("user:%d", id)).Err()
+}
+
+// GetPasswordHash возвращает bcrypt-хеш пароля пользователя
+func (m UserModel) GetPasswordHash(id int) (string, error) {
+	var passwordHash string
+
+	err := m.DB.QueryRow(`SELECT password_hash FROM users WHERE id = $1`, id).Scan(&passwordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrRecordNotFound
+		}
+		return "", err
+	}
+
+	return passwordHash, nil
+}
+
+// UpdatePassword сохраняет новый хеш пароля
+func (m UserModel) UpdatePassword(id int, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = now()
+		WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, passwordHash, id)
+	return err
+}
+
 func (m UserModel) Update(id int, input model.UpdateUserInput) (*model.User, error) {
 	query := `
 		UPDATE users
@@ -288,17 +485,18 @@ func (m UserModel) Update(id int, input model.UpdateUserInput) (*model.User, err
 			email = COALESCE(NULLIF($1, ''), email),
 			name = COALESCE(NULLIF($2, ''), name),
 			lastname = COALESCE(NULLIF($3, ''), lastname),
-			password_hash = COALESCE(NULLIF($4, ''), password_hash),
-			role = COALESCE($5, role),
-			image_url = COALESCE($6, image_url),
-			additional_information = COALESCE($7, additional_information),
-			course = COALESCE($8, course),
-			major = COALESCE($9, major),
-			degree = COALESCE($10, degree),
-			faculty = COALESCE($11, faculty),
+			role = COALESCE($4, role),
+			image_url = COALESCE($5, image_url),
+			additional_information = COALESCE($6, additional_information),
+			course = COALESCE($7, course),
+			major = COALESCE($8, major),
+			degree = COALESCE($9, degree),
+			faculty = COALESCE($10, faculty),
+			follow_approval_required = COALESCE($11, follow_approval_required),
 			updated_at = now()
 		WHERE id = $12
-		RETURNING id, email, name, lastname, password_hash, role, image_url, additional_information, course, major, degree, faculty, created_at, updated_at
+		RETURNING id, email, name, lastname, role, image_url, additional_information, course, major, degree, faculty, activated, follow_approval_required, followers_count, following_count, created_at, updated_at,
+		       email_visibility, course_visibility, major_visibility, faculty_visibility, additional_information_visibility
 	`
 
 	user := &model.User{}
@@ -306,7 +504,6 @@ func (m UserModel) Update(id int, input model.UpdateUserInput) (*model.User, err
 		input.Email,
 		input.Name,
 		input.Lastname,
-		input.Password,
 		input.Role,
 		input.ImageURL,
 		input.AdditionalInformation,
@@ -314,13 +511,13 @@ func (m UserModel) Update(id int, input model.UpdateUserInput) (*model.User, err
 		input.Major,
 		input.Degree,
 		input.Faculty,
+		input.RequiresFollowApproval,
 		id,
 	).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Name,
 		&user.Lastname,
-		&user.PasswordHash,
 		&user.Role,
 		&user.ImageURL,
 		&user.AdditionalInformation,
@@ -328,8 +525,17 @@ func (m UserModel) Update(id int, input model.UpdateUserInput) (*model.User, err
 		&user.Major,
 		&user.Degree,
 		&user.Faculty,
+		&user.Activated,
+		&user.RequiresFollowApproval,
+		&user.FollowersCount,
+		&user.FollowingCount,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Privacy.Email,
+		&user.Privacy.Course,
+		&user.Privacy.Major,
+		&user.Privacy.Faculty,
+		&user.Privacy.AdditionalInformation,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -340,3 +546,62 @@ func (m UserModel) Update(id int, input model.UpdateUserInput) (*model.User, err
 
 	return user, nil
 }
+
+// UpdatePrivacy меняет видимость полей профиля; незаданные поля остаются
+// прежними. Кеш профиля сбрасывается.
+func (m UserModel) UpdatePrivacy(id int, input model.UpdatePrivacySettingsInput) (*model.PrivacySettings, error) {
+	query := `
+		UPDATE users
+		SET
+			email_visibility = COALESCE($1, email_visibility),
+			course_visibility = COALESCE($2, course_visibility),
+			major_visibility = COALESCE($3, major_visibility),
+			faculty_visibility = COALESCE($4, faculty_visibility),
+			additional_information_visibility = COALESCE($5, additional_information_visibility),
+			updated_at = now()
+		WHERE id = $6
+		RETURNING email_visibility, course_visibility, major_visibility, faculty_visibility, additional_information_visibility
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var privacy model.PrivacySettings
+
+	err := m.DB.QueryRowContext(ctx, query,
+		input.Email,
+		input.Course,
+		input.Major,
+		input.Faculty,
+		input.AdditionalInformation,
+		id,
+	).Scan(
+		&privacy.Email,
+		&privacy.Course,
+		&privacy.Major,
+		&privacy.Faculty,
+		&privacy.AdditionalInformation,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &privacy, m.Redis.Del(ctx, fmt.Sprintf("user:%d", id)).Err()
+}
+
+// visibleCondition возвращает условие WHERE: поле пользователя (строка
+// таблицы users) с настройкой видимости visibilityColumn открыто зрителю
+// $viewerArg. Нужно для фильтров, чтобы по скрытому полю нельзя было искать.
+func visibleCondition(visibilityColumn string, viewerArg int) string {
+	return fmt.Sprintf(`(users.id = $%[1]d
+			OR %[2]s = 'EVERYONE'
+			OR (%[2]s = 'UNIVERSITY' AND EXISTS (SELECT 1 FROM users viewer WHERE viewer.id = $%[1]d AND viewer.activated))
+			OR (%[2]s = 'FOLLOWERS' AND EXISTS (
+				SELECT 1 FROM follows WHERE follower_id = $%[1]d AND followee_id = users.id AND status = 'accepted'
+			)))`, viewerArg, visibilityColumn)
+}
@@ -0,0 +1,82 @@
+package image
+
+import (
+	"cloud.google.com/go/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// GCSStorage хранит файлы в бакете Google Cloud Storage. Для подписи ссылок
+// нужны учетные данные сервисного аккаунта.
+type GCSStorage struct {
+	Client *storage.Client
+	Bucket string
+}
+
+func (st GCSStorage) Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error {
+	wc := st.Client.Bucket(st.Bucket).Object(key).NewWriter(ctx)
+
+	wc.ObjectAttrs.ContentType = contentType
+	wc.ObjectAttrs.CacheControl = "private, max-age=86400"
+
+	if _, err := io.Copy(wc, r); err != nil {
+		wc.Close()
+		return fmt.Errorf("writing %s to gcs: %w", key, err)
+	}
+
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("Writer.Close: %w", err)
+	}
+
+	return nil
+}
+
+func (st GCSStorage) Delete(ctx context.Context, key string) error {
+	err := st.Client.Bucket(st.Bucket).Object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrObjectNotFound
+	}
+
+	return err
+}
+
+func (st GCSStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return st.Client.Bucket(st.Bucket).SignedURL(key, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+	})
+}
+
+func (st GCSStorage) SignedPutURL(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	return st.Client.Bucket(st.Bucket).SignedURL(key, &storage.SignedURLOptions{
+		Scheme:      storage.SigningSchemeV4,
+		Method:      "PUT",
+		ContentType: contentType,
+		Expires:     time.Now().Add(ttl),
+	})
+}
+
+func (st GCSStorage) Size(ctx context.Context, key string) (int64, error) {
+	attrs, err := st.Client.Bucket(st.Bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return 0, ErrObjectNotFound
+		}
+		return 0, err
+	}
+
+	return attrs.Size, nil
+}
+
+func (st GCSStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := st.Client.Bucket(st.Bucket).Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrObjectNotFound
+	}
+
+	return rc, err
+}
@@ -0,0 +1,210 @@
+package image
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorage хранит файлы в каталоге на диске и сам раздает их по
+// подписанным ссылкам. Нужен для разработки и CI без доступа к облаку.
+type LocalStorage struct {
+	Dir string
+	// BaseURL — адрес, по которому смонтирован ServeHTTP, например
+	// http://localhost:4000/v1/files
+	BaseURL    string
+	SigningKey []byte
+}
+
+func (st LocalStorage) Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error {
+	path, err := st.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Пишем во временный файл, чтобы по ключу никогда не лежал недописанный файл
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (st LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := st.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrObjectNotFound
+	}
+
+	return err
+}
+
+func (st LocalStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return st.signedURL(http.MethodGet, key, ttl)
+}
+
+func (st LocalStorage) SignedPutURL(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	return st.signedURL(http.MethodPut, key, ttl)
+}
+
+func (st LocalStorage) Size(ctx context.Context, key string) (int64, error) {
+	path, err := st.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, ErrObjectNotFound
+		}
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+func (st LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := st.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+
+	return f, err
+}
+
+// signedURL подписывает метод вместе с ключом, чтобы ссылку на скачивание
+// нельзя было использовать для перезаписи файла
+func (st LocalStorage) signedURL(method, key string, ttl time.Duration) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("signature", st.sign(method, key, expires))
+
+	return strings.TrimSuffix(st.BaseURL, "/") + "/" + key + "?" + q.Encode(), nil
+}
+
+// ServeHTTP отдает (GET) или принимает (PUT) файл, если подпись ссылки
+// верна и срок ее не истек. Ключ берется из пути запроса, поэтому
+// обработчик монтируется с http.StripPrefix.
+func (st LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix || !hmac.Equal([]byte(signature), []byte(st.sign(method, key, expires))) {
+		http.Error(w, "link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	path, err := st.path(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		body := http.MaxBytesReader(w, r.Body, MaxFileSize)
+
+		err := st.Put(r.Context(), key, r.Header.Get("Content-Type"), body, r.ContentLength)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "file is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "upload failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Тип задается явно: иначе http.ServeFile определит его по содержимому
+	// и отдаст загруженный HTML как text/html
+	w.Header().Set("Content-Type", servedContentType(key))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, path)
+}
+
+// servedContentType возвращает MIME-тип файла по расширению ключа. Файлы
+// без известного расширения, например исходные загрузки, отдаются как
+// application/octet-stream.
+func servedContentType(key string) string {
+	ext := filepath.Ext(key)
+
+	if ext == ".zip" {
+		return "application/zip"
+	}
+
+	for mimeType, extension := range validImageTypes {
+		if ext == extension {
+			return mimeType
+		}
+	}
+
+	return "application/octet-stream"
+}
+
+func (st LocalStorage) sign(method, key, expires string) string {
+	mac := hmac.New(sha256.New, st.SigningKey)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (st LocalStorage) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+
+	return filepath.Join(st.Dir, filepath.FromSlash(key)), nil
+}
@@ -1,12 +1,11 @@
 package image
 
-var validImageTypes = map[string]bool{
-	"image/jpeg": true,
-	"image/png":  true,
+var validImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
 }
 
-func IsAllowedImageType(mimeType string) bool {
-	_, exist := validImageTypes[mimeType]
-
-	return exist
+// Extension возвращает расширение файла для допустимого MIME-типа
+func Extension(mimeType string) string {
+	return validImageTypes[mimeType]
 }
@@ -0,0 +1,157 @@
+package image
+
+import (
+	"encoding/binary"
+	"fmt"
+	stdimage "image"
+	"image/color"
+	"testing"
+)
+
+// segment собирает сегмент JPEG: маркер, длина и данные
+func segment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// tiffWithOrientation собирает заголовок TIFF с одной записью IFD — тегом
+// Orientation
+func tiffWithOrientation(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+
+	order.PutUint16(tiff[8:], 1)
+	entry := tiff[10:]
+	order.PutUint16(entry[0:], 0x0112)
+	order.PutUint16(entry[2:], 3)
+	order.PutUint32(entry[4:], 1)
+	order.PutUint16(entry[8:], orientation)
+
+	return tiff
+}
+
+func exifSegment(tiff []byte) []byte {
+	return segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func jpegWith(segments ...[]byte) []byte {
+	raw := []byte{0xFF, 0xD8}
+	for _, seg := range segments {
+		raw = append(raw, seg...)
+	}
+	return append(raw, 0xFF, 0xD9)
+}
+
+func TestExifOrientation(t *testing.T) {
+	withIFDOffset := func(offset uint32) []byte {
+		tiff := tiffWithOrientation(binary.BigEndian, 6)
+		binary.BigEndian.PutUint32(tiff[4:], offset)
+		return tiff
+	}
+
+	// Заявлено больше записей, чем есть: первая запись не Orientation,
+	// а вторая обрывается на середине
+	withTruncatedEntries := func() []byte {
+		tiff := tiffWithOrientation(binary.LittleEndian, 6)
+		binary.LittleEndian.PutUint16(tiff[8:], 1000)
+		binary.LittleEndian.PutUint16(tiff[10:], 0x0100)
+		return append(tiff[:22], 0x12, 0x01, 0x03, 0x00)
+	}
+
+	tests := []struct {
+		name string
+		raw  []byte
+		want int
+	}{
+		{"empty file", nil, 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"only SOI", []byte{0xFF, 0xD8}, 1},
+		{"truncated marker", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00}, 1},
+		{"no exif", jpegWith(segment(0xE0, []byte("JFIF\x00"))), 1},
+		{"little endian", jpegWith(exifSegment(tiffWithOrientation(binary.LittleEndian, 6))), 6},
+		{"big endian", jpegWith(exifSegment(tiffWithOrientation(binary.BigEndian, 6))), 6},
+		{"exif after APP0", jpegWith(segment(0xE0, []byte("JFIF\x00")), exifSegment(tiffWithOrientation(binary.BigEndian, 3))), 3},
+		{"exif after SOS", jpegWith(segment(0xDA, []byte{0, 0}), exifSegment(tiffWithOrientation(binary.BigEndian, 3))), 1},
+		{"zero-length segment", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00, 'E', 'x', 'i', 'f'}, 1},
+		{"segment longer than file", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF, 'E', 'x', 'i', 'f', 0, 0}, 1},
+		{"garbage between segments", append([]byte{0xFF, 0xD8, 0x00}, exifSegment(tiffWithOrientation(binary.BigEndian, 6))...), 1},
+		{"truncated tiff header", jpegWith(exifSegment([]byte("MM\x00*"))), 1},
+		{"unknown byte order", jpegWith(exifSegment(append([]byte("XX"), tiffWithOrientation(binary.BigEndian, 6)[2:]...))), 1},
+		{"ifd offset past end", jpegWith(exifSegment(withIFDOffset(1 << 20))), 1},
+		{"ifd offset at max uint32", jpegWith(exifSegment(withIFDOffset(0xFFFFFFFF))), 1},
+		{"entry count past end", jpegWith(exifSegment(withTruncatedEntries())), 1},
+		{"orientation 0", jpegWith(exifSegment(tiffWithOrientation(binary.BigEndian, 0))), 1},
+		{"orientation 9", jpegWith(exifSegment(tiffWithOrientation(binary.BigEndian, 9))), 1},
+	}
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		tests = append(tests, struct {
+			name string
+			raw  []byte
+			want int
+		}{
+			name: fmt.Sprintf("orientation %d", orientation),
+			raw:  jpegWith(exifSegment(tiffWithOrientation(binary.LittleEndian, uint16(orientation)))),
+			want: orientation,
+		})
+	}
+
+	for _, tt := range tests {
+		if got := exifOrientation(tt.raw); got != tt.want {
+			t.Errorf("%s: exifOrientation = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	const w, h = 3, 2
+
+	// Отмечаем левый верхний пиксель и его правого соседа, чтобы отличать
+	// поворот от отражения
+	src := stdimage.NewRGBA(stdimage.Rect(0, 0, w, h))
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, green)
+
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		red         stdimage.Point
+		green       stdimage.Point
+	}{
+		{1, w, h, stdimage.Pt(0, 0), stdimage.Pt(1, 0)},
+		{2, w, h, stdimage.Pt(w-1, 0), stdimage.Pt(w-2, 0)},
+		{3, w, h, stdimage.Pt(w-1, h-1), stdimage.Pt(w-2, h-1)},
+		{4, w, h, stdimage.Pt(0, h-1), stdimage.Pt(1, h-1)},
+		{5, h, w, stdimage.Pt(0, 0), stdimage.Pt(0, 1)},
+		{6, h, w, stdimage.Pt(h-1, 0), stdimage.Pt(h-1, 1)},
+		{7, h, w, stdimage.Pt(h-1, w-1), stdimage.Pt(h-1, w-2)},
+		{8, h, w, stdimage.Pt(0, w-1), stdimage.Pt(0, w-2)},
+	}
+
+	for _, tt := range tests {
+		dst := orient(src, tt.orientation)
+
+		if b := dst.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.width, tt.height)
+			continue
+		}
+
+		if got := color.RGBAModel.Convert(dst.At(tt.red.X, tt.red.Y)); got != red {
+			t.Errorf("orientation %d: pixel at %v = %v, want red", tt.orientation, tt.red, got)
+		}
+		if got := color.RGBAModel.Convert(dst.At(tt.green.X, tt.green.Y)); got != green {
+			t.Errorf("orientation %d: pixel at %v = %v, want green", tt.orientation, tt.green, got)
+		}
+	}
+}
@@ -1,7 +0,0 @@
-package image
-
-import "cloud.google.com/go/storage"
-
-type PostImageStorage struct {
-	Client *storage.Client
-}
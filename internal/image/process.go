@@ -0,0 +1,162 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	stdimage "image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// Ограничения на загружаемые изображения
+const (
+	MaxFileSize  = 8 << 20
+	MaxDimension = 8000
+	// MaxPixels защищает от «бомб»: маленький файл с огромным холстом
+	MaxPixels = 40_000_000
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrFileTooLarge      = errors.New("image file is too large")
+	ErrTooManyPixels     = errors.New("image dimensions are too large")
+)
+
+// Размеры вариантов: small — аватары и превью, medium — лента,
+// large — просмотр целиком. Число — длина большей стороны.
+const (
+	VariantSmall  = "small"
+	VariantMedium = "medium"
+	VariantLarge  = "large"
+)
+
+var variantSizes = []struct {
+	name string
+	size int
+}{
+	{VariantSmall, 160},
+	{VariantMedium, 640},
+	{VariantLarge, 1600},
+}
+
+var variantKeyRX = regexp.MustCompile(`^(.+)_(small|medium|large)(\.[a-z]+)$`)
+
+// VariantKey возвращает ключ варианта variant для ключа любого другого
+// варианта того же изображения: "posts/1/ab_large.jpg" → "posts/1/ab_small.jpg"
+func VariantKey(key, variant string) (string, bool) {
+	m := variantKeyRX.FindStringSubmatch(key)
+	if m == nil {
+		return "", false
+	}
+
+	return m[1] + "_" + variant + m[3], true
+}
+
+// Variant — готовый к сохранению вариант изображения
+type Variant struct {
+	Name        string
+	ContentType string
+	Extension   string
+	Data        []byte
+}
+
+// Process определяет формат по содержимому файла, а не по заявленному
+// клиентом типу, проверяет ограничения и перекодирует изображение во все
+// варианты. Перекодирование отбрасывает EXIF, в том числе координаты GPS;
+// ориентация из EXIF применяется к самим пикселям.
+func Process(r io.Reader) ([]Variant, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	// Прозрачность сохраняем только в PNG, остальное кодируем в JPEG
+	contentType := http.DetectContentType(raw)
+	switch contentType {
+	case "image/jpeg", "image/webp":
+		contentType = "image/jpeg"
+	case "image/png":
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	// Размеры проверяем по заголовку, до выделения памяти под пиксели
+	cfg, _, err := stdimage.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := stdimage.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	orientation := exifOrientation(raw)
+
+	variants := make([]Variant, 0, len(variantSizes))
+	for _, vs := range variantSizes {
+		// Поворачиваем уже уменьшенную копию: вписывание в квадрат от
+		// поворота не зависит, а пикселей в разы меньше
+		data, err := encode(orient(resize(src, vs.size), orientation), contentType)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s variant: %w", vs.name, err)
+		}
+
+		variants = append(variants, Variant{
+			Name:        vs.name,
+			ContentType: contentType,
+			Extension:   Extension(contentType),
+			Data:        data,
+		})
+	}
+
+	return variants, nil
+}
+
+// resize вписывает изображение в квадрат size×size с сохранением пропорций.
+// Маленькие изображения не увеличиваются, но все равно перерисовываются,
+// чтобы в результат не попали исходные метаданные.
+func resize(src stdimage.Image, size int) stdimage.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+
+	dst := stdimage.NewRGBA(stdimage.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	return dst
+}
+
+func encode(img stdimage.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+
+	return buf.Bytes(), err
+}
@@ -0,0 +1,162 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	stdimage "image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// webp1x1 — изображение WebP (lossless) размером 1×1
+var webp1x1 = []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+func testImage(w, h int) stdimage.Image {
+	img := stdimage.NewRGBA(stdimage.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img stdimage.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img stdimage.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader возвращает начало PNG, в котором заявлены размеры w×h. Для
+// DecodeConfig пикселей не нужно, поэтому так проверяются «бомбы».
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 4+13)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+	ihdr[12] = 8 // глубина цвета
+	ihdr[13] = 6 // RGBA
+
+	raw := []byte("\x89PNG\r\n\x1a\n")
+	raw = binary.BigEndian.AppendUint32(raw, 13)
+	raw = append(raw, ihdr...)
+	raw = binary.BigEndian.AppendUint32(raw, crc32.ChecksumIEEE(ihdr))
+
+	return raw
+}
+
+func TestProcess(t *testing.T) {
+	// JPEG 4×2 с EXIF Orientation 6: после поворота варианты вертикальные
+	rotated := encodeJPEG(t, testImage(4, 2))
+	rotated = append(rotated[:2], append(exifSegment(tiffWithOrientation(binary.BigEndian, 6)), rotated[2:]...)...)
+
+	tests := []struct {
+		name        string
+		raw         []byte
+		err         error
+		contentType string
+		width       int
+		height      int
+	}{
+		{"jpeg", encodeJPEG(t, testImage(20, 10)), nil, "image/jpeg", 20, 10},
+		{"png keeps png", encodePNG(t, testImage(20, 10)), nil, "image/png", 20, 10},
+		{"webp becomes jpeg", webp1x1, nil, "image/jpeg", 1, 1},
+		{"exif orientation applied", rotated, nil, "image/jpeg", 2, 4},
+		{"empty file", nil, ErrUnsupportedFormat, "", 0, 0},
+		{"html", []byte("<!DOCTYPE html><html><script>alert(1)</script></html>"), ErrUnsupportedFormat, "", 0, 0},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"), ErrUnsupportedFormat, "", 0, 0},
+		{"jpeg magic with garbage", append([]byte{0xFF, 0xD8, 0xFF}, bytes.Repeat([]byte{0}, 64)...), ErrUnsupportedFormat, "", 0, 0},
+		{"too wide", pngHeader(MaxDimension+1, 1), ErrTooManyPixels, "", 0, 0},
+		{"too tall", pngHeader(1, MaxDimension+1), ErrTooManyPixels, "", 0, 0},
+		{"too many pixels", pngHeader(MaxDimension, MaxPixels/MaxDimension+1), ErrTooManyPixels, "", 0, 0},
+		{"file too large", append(encodePNG(t, testImage(1, 1)), make([]byte, MaxFileSize)...), ErrFileTooLarge, "", 0, 0},
+	}
+
+	for _, tt := range tests {
+		variants, err := Process(bytes.NewReader(tt.raw))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if len(variants) != len(variantSizes) {
+			t.Errorf("%s: got %d variants, want %d", tt.name, len(variants), len(variantSizes))
+			continue
+		}
+
+		for _, variant := range variants {
+			if variant.ContentType != tt.contentType || variant.Extension != Extension(tt.contentType) {
+				t.Errorf("%s: %s variant is %s (%s), want %s", tt.name, variant.Name, variant.ContentType, variant.Extension, tt.contentType)
+			}
+
+			// Тип результата определяется по байтам, а не по полю ContentType
+			cfg, format, err := stdimage.DecodeConfig(bytes.NewReader(variant.Data))
+			if err != nil {
+				t.Errorf("%s: %s variant does not decode: %v", tt.name, variant.Name, err)
+				continue
+			}
+			if "image/"+format != tt.contentType {
+				t.Errorf("%s: %s variant is encoded as %s, want %s", tt.name, variant.Name, format, tt.contentType)
+			}
+			if cfg.Width != tt.width || cfg.Height != tt.height {
+				t.Errorf("%s: %s variant is %dx%d, want %dx%d", tt.name, variant.Name, cfg.Width, cfg.Height, tt.width, tt.height)
+			}
+		}
+	}
+}
+
+func TestProcessResizesVariants(t *testing.T) {
+	variants, err := Process(bytes.NewReader(encodePNG(t, testImage(2000, 1000))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, variant := range variants {
+		cfg, err := png.DecodeConfig(bytes.NewReader(variant.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size := variantSizes[i].size
+		if cfg.Width != size || cfg.Height != size/2 {
+			t.Errorf("%s variant is %dx%d, want %dx%d", variant.Name, cfg.Width, cfg.Height, size, size/2)
+		}
+	}
+}
+
+func TestProcessStripsExif(t *testing.T) {
+	raw := encodeJPEG(t, testImage(8, 8))
+	raw = append(raw[:2], append(exifSegment(tiffWithOrientation(binary.LittleEndian, 1)), raw[2:]...)...)
+
+	variants, err := Process(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, variant := range variants {
+		if bytes.Contains(variant.Data, []byte("Exif\x00\x00")) {
+			t.Errorf("%s variant still contains EXIF", variant.Name)
+		}
+	}
+}
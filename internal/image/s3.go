@@ -0,0 +1,88 @@
+package image
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"net/url"
+	"time"
+)
+
+// S3Config описывает S3-совместимое хранилище: AWS S3, MinIO, Yandex Object
+// Storage и т. п.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Bucket    string
+}
+
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Storage{client: client, bucket: cfg.Bucket}, nil
+}
+
+func (st *S3Storage) Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error {
+	_, err := st.client.PutObject(ctx, st.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "private, max-age=86400",
+	})
+
+	return err
+}
+
+func (st *S3Storage) Delete(ctx context.Context, key string) error {
+	return st.client.RemoveObject(ctx, st.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (st *S3Storage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := st.client.PresignedGetObject(ctx, st.bucket, key, ttl, url.Values{})
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// SignedPutURL: в отличие от GCS, подпись S3 не фиксирует Content-Type,
+// поэтому тип файла проверяется при подтверждении загрузки
+func (st *S3Storage) SignedPutURL(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	u, err := st.client.PresignedPutObject(ctx, st.bucket, key, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+func (st *S3Storage) Size(ctx context.Context, key string) (int64, error) {
+	info, err := st.client.StatObject(ctx, st.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return 0, ErrObjectNotFound
+		}
+		return 0, err
+	}
+
+	return info.Size, nil
+}
+
+func (st *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return st.client.GetObject(ctx, st.bucket, key, minio.GetObjectOptions{})
+}
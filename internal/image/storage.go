@@ -0,0 +1,46 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Бэкенды хранилища, выбираемые конфигурацией
+const (
+	BackendGCS   = "gcs"
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+var Backends = []string{BackendGCS, BackendS3, BackendLocal}
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// Storage хранит файлы под ключами вида "posts/42/<имя>.jpg". Файлы не
+// публичны: скачать их можно только по подписанной ссылке с ограниченным
+// сроком действия.
+type Storage interface {
+	// Put сохраняет файл; size может быть -1, если размер неизвестен
+	Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error
+	Delete(ctx context.Context, key string) error
+	// SignedURL возвращает ссылку на скачивание, действующую ttl
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// SignedPutURL возвращает ссылку, по которой клиент сам загружает файл
+	// запросом PUT с заголовком Content-Type, равным contentType
+	SignedPutURL(ctx context.Context, key, contentType string, ttl time.Duration) (string, error)
+	// Size возвращает размер файла или ErrObjectNotFound
+	Size(ctx context.Context, key string) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+var keyRX = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*(/[a-z0-9][a-z0-9_.-]*)*$`)
+
+// ValidKey проверяет, что ключ не выходит за пределы хранилища и состоит
+// только из символов, которые мы сами используем в именах
+func ValidKey(key string) bool {
+	return len(key) <= 255 && keyRX.MatchString(key) && !strings.Contains(key, "..")
+}
@@ -0,0 +1,2 @@
+-- Индекс для keyset-пагинации комментариев внутри поста/топика
+CREATE INDEX IF NOT EXISTS idx_comments_entity_id ON comments(entity_type, entity_id, id);
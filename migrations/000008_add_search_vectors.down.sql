@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_posts_search_vector;
+DROP INDEX IF EXISTS idx_topics_search_vector;
+DROP INDEX IF EXISTS idx_clubs_search_vector;
+DROP INDEX IF EXISTS idx_events_search_vector;
+DROP INDEX IF EXISTS idx_users_search_vector;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE topics DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE clubs DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE events DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
+
+DROP FUNCTION IF EXISTS multilingual_tsquery(TEXT);
+DROP FUNCTION IF EXISTS multilingual_tsvector(TEXT, "char");
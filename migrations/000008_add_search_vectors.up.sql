@@ -0,0 +1,38 @@
+-- Конфигурация russian стеммит кириллицу русским стеммером, а латиницу — английским.
+-- Для казахского словаря в PostgreSQL нет, поэтому дополнительно индексируем
+-- слова без стемминга через simple.
+CREATE OR REPLACE FUNCTION multilingual_tsvector(body TEXT, weight "char") RETURNS tsvector
+    LANGUAGE sql IMMUTABLE AS $$
+SELECT setweight(to_tsvector('russian', coalesce(body, '')) || to_tsvector('simple', coalesce(body, '')), weight)
+$$;
+
+CREATE OR REPLACE FUNCTION multilingual_tsquery(query TEXT) RETURNS tsquery
+    LANGUAGE sql IMMUTABLE AS $$
+SELECT websearch_to_tsquery('russian', query) || websearch_to_tsquery('simple', query)
+$$;
+
+ALTER TABLE posts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    multilingual_tsvector(title, 'A') || multilingual_tsvector(content, 'B')
+) STORED;
+
+ALTER TABLE topics ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    multilingual_tsvector(title, 'A') || multilingual_tsvector(content, 'B')
+) STORED;
+
+ALTER TABLE clubs ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    multilingual_tsvector(name, 'A') || multilingual_tsvector(description, 'B')
+) STORED;
+
+ALTER TABLE events ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    multilingual_tsvector(title, 'A') || multilingual_tsvector(description, 'B')
+) STORED;
+
+ALTER TABLE users ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    multilingual_tsvector(name || ' ' || lastname, 'A')
+) STORED;
+
+CREATE INDEX idx_posts_search_vector ON posts USING GIN (search_vector);
+CREATE INDEX idx_topics_search_vector ON topics USING GIN (search_vector);
+CREATE INDEX idx_clubs_search_vector ON clubs USING GIN (search_vector);
+CREATE INDEX idx_events_search_vector ON events USING GIN (search_vector);
+CREATE INDEX idx_users_search_vector ON users USING GIN (search_vector);
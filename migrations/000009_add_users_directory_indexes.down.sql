@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_users_created_at_id;
+DROP INDEX IF EXISTS idx_users_course_id;
+DROP INDEX IF EXISTS idx_users_lastname_id;
+DROP INDEX IF EXISTS idx_users_name_id;
+DROP INDEX IF EXISTS idx_users_full_name_trgm;
+DROP INDEX IF EXISTS idx_users_role;
+DROP INDEX IF EXISTS idx_users_degree;
+DROP INDEX IF EXISTS idx_users_major_course;
+DROP INDEX IF EXISTS idx_users_faculty_course;
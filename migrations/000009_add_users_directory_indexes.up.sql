@@ -0,0 +1,16 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Фильтры справочника пользователей: факультет/специальность + курс, роль
+CREATE INDEX IF NOT EXISTS idx_users_faculty_course ON users(faculty, course);
+CREATE INDEX IF NOT EXISTS idx_users_major_course ON users(major, course);
+CREATE INDEX IF NOT EXISTS idx_users_degree ON users(degree);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
+
+-- Поиск по подстроке имени (ILIKE '%...%')
+CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users USING GIN ((name || ' ' || lastname) gin_trgm_ops);
+
+-- Keyset-сортировки справочника
+CREATE INDEX IF NOT EXISTS idx_users_name_id ON users(name, id);
+CREATE INDEX IF NOT EXISTS idx_users_lastname_id ON users(lastname, id);
+CREATE INDEX IF NOT EXISTS idx_users_course_id ON users((COALESCE(course, 0)), id);
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
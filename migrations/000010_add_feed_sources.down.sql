@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_club_members_user;
+DROP INDEX IF EXISTS idx_events_club_created_at;
+DROP INDEX IF EXISTS idx_posts_club_created_at;
+DROP INDEX IF EXISTS idx_posts_author_created_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS club_id;
+
+DROP TABLE IF EXISTS follows;
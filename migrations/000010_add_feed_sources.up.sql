@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follows_followee ON follows(followee_id);
+
+-- Пост с club_id — объявление, опубликованное администратором клуба
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS club_id INT REFERENCES clubs(id) ON DELETE CASCADE;
+
+-- Индексы для сборки ленты
+CREATE INDEX IF NOT EXISTS idx_posts_author_created_at ON posts(author_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_club_created_at ON posts(club_id, created_at DESC) WHERE club_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_events_club_created_at ON events(club_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_club_members_user ON club_members(user_id);
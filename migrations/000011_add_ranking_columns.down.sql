@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_topics_created_at;
+DROP INDEX IF EXISTS idx_posts_created_at;
+DROP INDEX IF EXISTS idx_topics_comments_count_id;
+DROP INDEX IF EXISTS idx_topics_likes_id;
+DROP INDEX IF EXISTS idx_posts_comments_count_id;
+DROP INDEX IF EXISTS idx_posts_likes_id;
+
+DROP TRIGGER IF EXISTS comments_count_trigger ON comments;
+DROP FUNCTION IF EXISTS update_comments_count();
+
+ALTER TABLE topics DROP COLUMN IF EXISTS comments_count;
+ALTER TABLE posts DROP COLUMN IF EXISTS comments_count;
@@ -0,0 +1,44 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS comments_count INT NOT NULL DEFAULT 0;
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS comments_count INT NOT NULL DEFAULT 0;
+
+UPDATE posts p SET comments_count = (
+    SELECT count(*) FROM comments c WHERE c.entity_type = 'post' AND c.entity_id = p.id
+);
+
+UPDATE topics t SET comments_count = (
+    SELECT count(*) FROM comments c WHERE c.entity_type = 'topic' AND c.entity_id = t.id
+);
+
+-- Счётчик комментариев поддерживается триггером, чтобы сортировка
+-- MOST_COMMENTED и hot-рейтинг не считали комментарии на каждый запрос
+CREATE OR REPLACE FUNCTION update_comments_count() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.entity_type = 'post' THEN
+            UPDATE posts SET comments_count = comments_count + 1 WHERE id = NEW.entity_id;
+        ELSIF NEW.entity_type = 'topic' THEN
+            UPDATE topics SET comments_count = comments_count + 1 WHERE id = NEW.entity_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF OLD.entity_type = 'post' THEN
+        UPDATE posts SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = OLD.entity_id;
+    ELSIF OLD.entity_type = 'topic' THEN
+        UPDATE topics SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = OLD.entity_id;
+    END IF;
+    RETURN OLD;
+END;
+$$;
+
+CREATE TRIGGER comments_count_trigger
+    AFTER INSERT OR DELETE ON comments
+    FOR EACH ROW EXECUTE FUNCTION update_comments_count();
+
+CREATE INDEX IF NOT EXISTS idx_posts_likes_id ON posts(likes, id);
+CREATE INDEX IF NOT EXISTS idx_posts_comments_count_id ON posts(comments_count, id);
+CREATE INDEX IF NOT EXISTS idx_topics_likes_id ON topics(likes, id);
+CREATE INDEX IF NOT EXISTS idx_topics_comments_count_id ON topics(comments_count, id);
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at);
+CREATE INDEX IF NOT EXISTS idx_topics_created_at ON topics(created_at);
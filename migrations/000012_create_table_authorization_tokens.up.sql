@@ -0,0 +1,19 @@
+-- Refresh токены: в базе хранится только sha256-хеш. Все токены одной сессии
+-- (цепочка ротаций) объединены общим session_id.
+CREATE TABLE IF NOT EXISTS authorization_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(64) NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expiry TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- Заполняется при ротации; повторное предъявление такого токена — признак кражи
+    used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_authorization_tokens_session ON authorization_tokens(session_id);
+CREATE INDEX IF NOT EXISTS idx_authorization_tokens_user_active ON authorization_tokens(user_id)
+    WHERE used_at IS NULL AND revoked_at IS NULL;
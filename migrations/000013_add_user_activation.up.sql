@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS activated BOOLEAN NOT NULL DEFAULT false;
+
+-- Уже зарегистрированные пользователи считаются подтвержденными
+UPDATE users SET activated = true;
+
+CREATE TABLE IF NOT EXISTS tokens (
+    hash BYTEA PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expiry TIMESTAMP WITH TIME ZONE NOT NULL,
+    scope TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user_scope ON tokens(user_id, scope);
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id BIGSERIAL PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE
+);
+
+-- Права, которые пользователь получает вместе с ролью
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL,
+    permission_id BIGINT NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission_id)
+);
+
+-- Индивидуальные исключения: granted = false отнимает право, выданное ролью
+CREATE TABLE IF NOT EXISTS users_permissions (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission_id BIGINT NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    granted BOOLEAN NOT NULL DEFAULT true,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+INSERT INTO permissions (code)
+VALUES ('posts:moderate'), ('clubs:create'), ('users:manage')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT r.role, p.id
+FROM (VALUES
+    ('STUDENT', 'clubs:create'),
+    ('TEACHER', 'clubs:create'),
+    ('TEACHER', 'posts:moderate'),
+    ('ADMIN', 'clubs:create'),
+    ('ADMIN', 'posts:moderate'),
+    ('ADMIN', 'users:manage')
+) AS r(role, code)
+INNER JOIN permissions p ON p.code = r.code
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+ALTER TABLE authorization_tokens DROP COLUMN IF EXISTS mfa;
+
+DROP TABLE IF EXISTS mfa_required_roles;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS users_totp;
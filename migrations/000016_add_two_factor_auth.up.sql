@@ -0,0 +1,26 @@
+-- TOTP: секрет хранится зашифрованным ключом приложения. Пока confirmed_at
+-- пуст, 2FA не включена. last_used_step защищает от повторного ввода кода.
+CREATE TABLE IF NOT EXISTS users_totp (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret_encrypted BYTEA NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Одноразовые коды восстановления (sha256)
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Роли, для которых администратор сделал 2FA обязательной
+CREATE TABLE IF NOT EXISTS mfa_required_roles (
+    role VARCHAR(50) PRIMARY KEY
+);
+
+-- Сессии, открытые с подтверждением вторым фактором
+ALTER TABLE authorization_tokens ADD COLUMN IF NOT EXISTS mfa BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,14 @@
+-- Журнал событий безопасности: входы, неудачные попытки, блокировки.
+-- user_id пуст, если попытка была с email, которого нет в базе.
+CREATE TABLE IF NOT EXISTS security_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    email VARCHAR(255) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(64),
+    user_agent TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_created_at ON security_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_security_events_ip_created_at ON security_events(ip_address, created_at DESC);
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS follows_count_trigger ON follows;
+DROP FUNCTION IF EXISTS update_follows_count();
+
+ALTER TABLE users DROP COLUMN IF EXISTS following_count;
+ALTER TABLE users DROP COLUMN IF EXISTS followers_count;
+
+DROP INDEX IF EXISTS idx_follows_followee_status;
+ALTER TABLE follows DROP COLUMN IF EXISTS status;
+
+ALTER TABLE users DROP COLUMN IF EXISTS follow_approval_required;
@@ -0,0 +1,40 @@
+-- Закрытый аккаунт: новые подписки ждут одобрения владельца
+ALTER TABLE users ADD COLUMN IF NOT EXISTS follow_approval_required BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE follows ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'accepted'
+    CHECK (status IN ('accepted', 'pending'));
+
+CREATE INDEX IF NOT EXISTS idx_follows_followee_status ON follows(followee_id, status, created_at DESC);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS following_count INT NOT NULL DEFAULT 0;
+
+UPDATE users u SET
+    followers_count = (SELECT count(*) FROM follows f WHERE f.followee_id = u.id AND f.status = 'accepted'),
+    following_count = (SELECT count(*) FROM follows f WHERE f.follower_id = u.id AND f.status = 'accepted');
+
+-- Счётчики учитывают только одобренные подписки и поддерживаются триггером,
+-- как comments_count у постов
+CREATE OR REPLACE FUNCTION update_follows_count() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.status = 'accepted' THEN
+        UPDATE users SET followers_count = GREATEST(followers_count - 1, 0) WHERE id = OLD.followee_id;
+        UPDATE users SET following_count = GREATEST(following_count - 1, 0) WHERE id = OLD.follower_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.status = 'accepted' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.followee_id;
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER follows_count_trigger
+    AFTER INSERT OR UPDATE OF status OR DELETE ON follows
+    FOR EACH ROW EXECUTE FUNCTION update_follows_count();
@@ -0,0 +1,20 @@
+-- Заблокированный пользователь не видит профиль и контент заблокировавшего,
+-- не может комментировать его посты, отвечать на его комментарии и подписываться
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON user_blocks(blocked_id);
+
+-- Контент заглушенного пользователя скрыт только из списков заглушившего
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
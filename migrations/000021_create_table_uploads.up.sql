@@ -0,0 +1,17 @@
+-- Файлы, которые клиент загружает в хранилище напрямую по подписанной
+-- ссылке. Поля imageURL принимают только id подтвержденных загрузок.
+CREATE TABLE IF NOT EXISTS uploads (
+    id TEXT PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('post', 'topic', 'club', 'event', 'comment', 'avatar')),
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed')),
+    -- Ключ варианта large после обработки изображения
+    image_key TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    attached_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_user_id ON uploads(user_id);
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_visibility,
+    DROP COLUMN IF EXISTS course_visibility,
+    DROP COLUMN IF EXISTS major_visibility,
+    DROP COLUMN IF EXISTS faculty_visibility,
+    DROP COLUMN IF EXISTS additional_information_visibility;
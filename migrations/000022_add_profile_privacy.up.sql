@@ -0,0 +1,14 @@
+-- Кто видит поля профиля: EVERYONE — все, включая анонимных,
+-- UNIVERSITY — пользователи с подтвержденным аккаунтом,
+-- FOLLOWERS — одобренные подписчики, NOBODY — только владелец
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_visibility VARCHAR(10) NOT NULL DEFAULT 'UNIVERSITY'
+        CHECK (email_visibility IN ('EVERYONE', 'UNIVERSITY', 'FOLLOWERS', 'NOBODY')),
+    ADD COLUMN IF NOT EXISTS course_visibility VARCHAR(10) NOT NULL DEFAULT 'UNIVERSITY'
+        CHECK (course_visibility IN ('EVERYONE', 'UNIVERSITY', 'FOLLOWERS', 'NOBODY')),
+    ADD COLUMN IF NOT EXISTS major_visibility VARCHAR(10) NOT NULL DEFAULT 'UNIVERSITY'
+        CHECK (major_visibility IN ('EVERYONE', 'UNIVERSITY', 'FOLLOWERS', 'NOBODY')),
+    ADD COLUMN IF NOT EXISTS faculty_visibility VARCHAR(10) NOT NULL DEFAULT 'UNIVERSITY'
+        CHECK (faculty_visibility IN ('EVERYONE', 'UNIVERSITY', 'FOLLOWERS', 'NOBODY')),
+    ADD COLUMN IF NOT EXISTS additional_information_visibility VARCHAR(10) NOT NULL DEFAULT 'UNIVERSITY'
+        CHECK (additional_information_visibility IN ('EVERYONE', 'UNIVERSITY', 'FOLLOWERS', 'NOBODY'));
@@ -0,0 +1,35 @@
+-- Удаление аккаунта: до deletion_scheduled_at его можно отменить, после
+-- профиль обезличивается, а контент удаляется (см. UserModel.Purge).
+-- Строка пользователя остается, чтобы на нее могли ссылаться сохраненные
+-- ответы в чужих обсуждениях и созданные пользователем клубы.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL;
+
+-- Каскад от users удалял комментарии автора, а вслед за ними по parent_id
+-- и ответы всех остальных; лайки исчезали, не уменьшая счетчики. Без этих
+-- ограничений остаются исходные внешние ключи без каскада, и удалить
+-- пользователя вместе с контентом база не даст.
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS fk_post_author;
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comment_author;
+ALTER TABLE likes DROP CONSTRAINT IF EXISTS fk_like_user;
+
+-- Архивы с данными пользователя. Ссылка на скачивание приходит на email,
+-- в базе хранится только хеш токена из нее.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'ready', 'failed')),
+    token_hash BYTEA UNIQUE,
+    object_key TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status);